@@ -1,27 +1,21 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/bwmarrin/discordgo"
 	"io"
-	"log"
+	"net/http"
 	"os"
 	"os/signal"
-	"path"
-	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
 var (
-	// loaded models
-	wordModels = make([]*WordModel, 0)
-	// logger for writing to log file
-	logger = &log.Logger{}
-
-	// choices for text generation model option
-	generateTextModelChoices = make([]*discordgo.ApplicationCommandOptionChoice, 0)
-
 	// slice of bot commands
 	botCommands = []*discordgo.ApplicationCommand{
 		{
@@ -29,11 +23,11 @@ var (
 			Description: "Generate random text",
 			Options: []*discordgo.ApplicationCommandOption{
 				{
-					Type:        discordgo.ApplicationCommandOptionInteger,
-					Name:        "model",
-					Description: "Model to use for generating text",
-					Choices:     generateTextModelChoices,
-					Required:    true,
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "model",
+					Description:  "Model to use for generating text",
+					Autocomplete: true,
+					Required:     true,
 				},
 				{
 					Type:        discordgo.ApplicationCommandOptionInteger,
@@ -44,21 +38,252 @@ var (
 				},
 			},
 		},
+		{
+			Name:        "hurabot",
+			Description: "Manage the bot",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "prune",
+					Description: "Remove words, phrases or messages from a model",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:         discordgo.ApplicationCommandOptionString,
+							Name:         "model",
+							Description:  "Model to prune",
+							Autocomplete: true,
+							Required:     true,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "phrase",
+							Description: "Word or phrase to remove",
+							Required:    false,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "regex",
+							Description: "Regular expression for words to remove",
+							Required:    false,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "channel",
+							Description: "ID of a channel whose messages to remove",
+							Required:    false,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "from",
+							Description: "Remove messages sent on or after this date (YYYY-MM-DD)",
+							Required:    false,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "until",
+							Description: "Remove messages sent on or before this date (YYYY-MM-DD)",
+							Required:    false,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "list",
+					Description: "List the models found",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "reload",
+					Description: "Reload changed models",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "enable",
+					Description: "Enable a disabled model",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:         discordgo.ApplicationCommandOptionString,
+							Name:         "model",
+							Description:  "Model to enable",
+							Autocomplete: true,
+							Required:     true,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "disable",
+					Description: "Disable a model so it can't be used for generating text",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:         discordgo.ApplicationCommandOptionString,
+							Name:         "model",
+							Description:  "Model to disable",
+							Autocomplete: true,
+							Required:     true,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "maxwords",
+					Description: "Change the maximum amount of words that can be generated",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        "words",
+							Description: "Maximum amount of words",
+							Required:    true,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "stats",
+					Description: "Show uptime, statistics & usage leaderboards",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        "days",
+							Description: "Days of usage to show, 7 if not given",
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+					Name:        "schedule",
+					Description: "Manage scheduled messages",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "add",
+							Description: "Post generated text on a schedule",
+							Options: []*discordgo.ApplicationCommandOption{
+								{
+									Type:        discordgo.ApplicationCommandOptionString,
+									Name:        "id",
+									Description: "Unique ID of the schedule",
+									Required:    true,
+								},
+								{
+									Type:         discordgo.ApplicationCommandOptionChannel,
+									Name:         "channel",
+									Description:  "Channel to post to",
+									ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+									Required:     true,
+								},
+								{
+									Type:         discordgo.ApplicationCommandOptionString,
+									Name:         "model",
+									Description:  "Model to use",
+									Autocomplete: true,
+									Required:     true,
+								},
+								{
+									Type:        discordgo.ApplicationCommandOptionString,
+									Name:        "cron",
+									Description: "When to post as a cron expression, for example 0 9 * * * for every day at 9:00",
+									Required:    true,
+								},
+								{
+									Type:        discordgo.ApplicationCommandOptionInteger,
+									Name:        "words",
+									Description: "Amount of words to generate",
+									Required:    false,
+								},
+								{
+									Type:        discordgo.ApplicationCommandOptionString,
+									Name:        "timezone",
+									Description: "Timezone of the schedule, for example Europe/Helsinki",
+									Required:    false,
+								},
+							},
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "remove",
+							Description: "Remove a schedule",
+							Options: []*discordgo.ApplicationCommandOption{
+								{
+									Type:        discordgo.ApplicationCommandOptionString,
+									Name:        "id",
+									Description: "ID of the schedule",
+									Required:    true,
+								},
+							},
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "list",
+							Description: "List the schedules",
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "fetch",
+					Description: "Create a new model from the messages of a channel",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:         discordgo.ApplicationCommandOptionChannel,
+							Name:         "channel",
+							Description:  "Channel to fetch",
+							ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+							Required:     true,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "file",
+							Description: "Filename of the new model, use the same filename to continue an interrupted fetch",
+							Required:    true,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionUser,
+							Name:        "author",
+							Description: "Only use the messages of this user",
+							Required:    false,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        "limit",
+							Description: "Maximum amount of messages to use",
+							Required:    false,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "name",
+							Description: "Name of the model",
+							Required:    false,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "description",
+							Description: "Description of the model",
+							Required:    false,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "optout",
+					Description: "Stop models that contain your messages from being used",
+				},
+			},
+		},
+		{
+			Name: "Reply as model",
+			Type: discordgo.MessageApplicationCommand,
+		},
 	}
 	// map of command handlers
-	commandHandlers = map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate){
+	commandHandlers = map[string]func(s botSession, i *discordgo.InteractionCreate){
 		// handler for generate-text command
-		"generate-text": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+		"generate-text": func(s botSession, i *discordgo.InteractionCreate) {
 			options := i.ApplicationCommandData().Options
 
-			// check if message was sent from a guild or from a DM and log accordingly
-			// TODO add given options to the log message
-			if i.Member != nil {
-				logger.Printf("Received command request from %s#%s: %v\n", i.Member.User.Username, i.Member.User.Discriminator,
-					i.ApplicationCommandData())
-			} else if i.User != nil {
-				logger.Printf("Received command request from %s#%s: %v\n", i.User.Username, i.User.Discriminator,
-					i.ApplicationCommandData())
+			if user := interactionUser(i); user != nil {
+				logger.Info("Received command request", "command", i.ApplicationCommandData().Name,
+					"user", user.Username+"#"+user.Discriminator, "guild", i.GuildID, "options", optionValues(options))
 			}
 
 			// make map of the options received
@@ -67,15 +292,51 @@ var (
 				optionMap[opt.Name] = opt
 			}
 
-			msg := "Generating text with "
+			// models can be reloaded while the bot runs, check that the chosen one still exists.
+			// Models that can't be used here are treated as unknown so they aren't revealed
+			model := getModel(optionMap["model"].StringValue())
+			if model == nil || canUseModel(model.Info.ID, i) == false || guildHasModel(i.GuildID, model) == false {
+				respondEphemeral(s, i, "Unknown model "+optionMap["model"].StringValue())
+				return
+			}
+
+			if isModelDisabled(model.Info.ID) {
+				respondEphemeral(s, i, "Model "+model.Info.Name+" is disabled")
+				return
+			}
+
+			if hasOptedOutContributors(model) {
+				respondEphemeral(s, i, "Model "+model.Info.Name+" contains messages from users who have opted out, "+
+					"it can't be used until it's made again")
+				return
+			}
+
+			wordModel, err := model.load()
+			if err != nil {
+				logger.Error("Failed to load model", "model", model.Info.ID, "error", err)
+				respondEphemeral(s, i, "Failed to load model "+model.Info.Name)
+				return
+			}
 
+			// the live model is empty until it has recorded messages
+			if len(wordModel.Words) < 1 {
+				respondEphemeral(s, i, "Model "+model.Info.Name+" has no words yet")
+				return
+			}
+
+			// set value for amount of words if it was supplied
+			settings := guildSettings(i.GuildID)
+			var amountOfWords = settings.DefaultWords
 			if option, ok := optionMap["words"]; ok {
-				msg += strconv.Itoa(int(option.IntValue())) + " words"
-			} else {
-				msg += "50 words"
+				amountOfWords = int(option.IntValue())
+			}
+
+			// the maximum can be lowered while the bot runs
+			if amountOfWords > settings.MaxWords {
+				amountOfWords = settings.MaxWords
 			}
 
-			msg += " using model " + wordModels[optionMap["model"].IntValue()].Name
+			msg := generationHeader(amountOfWords, wordModel.Name)
 
 			// send response
 			if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
@@ -84,51 +345,76 @@ var (
 					Content: msg,
 				},
 			}); err != nil {
-				logger.Printf("Failed to send interaction response: %v\n", err)
+				logger.Error("Failed to send interaction response", "error", err)
 			}
 
 			// generate the text
-			var generatedText string
-			var amountOfWords = 50
+			logger.Info("Generating text", "words", amountOfWords, "model", wordModel.ID)
+			generatedText, err := generateText(wordModel, &amountOfWords, nil)
 
-			// set value for amount of words if it was supplied
-			if option, ok := optionMap["words"]; ok {
-				amountOfWords = int(option.IntValue())
+			// the bot started shutting down while generating
+			if err != nil {
+				if _, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+					Content: "The bot is shutting down, try again later",
+				}); err != nil {
+					logger.Error("Failed to edit message", "error", err)
+				}
+				return
 			}
+			recordInteractionUsage(i, wordModel.ID)
 
-			generatedText = GenerateWords(wordModels[optionMap["model"].IntValue()], &amountOfWords)
-
-			// split text to max 2000 letter messages
-			messagesToSend := splitText(msg + "\n\n" + generatedText)
-
-			// send messages
-			for index := range messagesToSend {
-				// edit the first message
-				if index == 0 {
-					_, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
-						Content: messagesToSend[index],
-					})
-					if err != nil {
-						logger.Printf("Failed to edit message: %v\n", err)
-
-						if _, err := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
-							Content: "Something went wrong",
-						}); err != nil {
-							logger.Printf("Failed to send followup message: %v\n", err)
-						}
-						return
-					}
-				} else {
-					// send the rest as followup messages
-					if _, err := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
-						Content: messagesToSend[index],
-					}); err != nil {
-						logger.Printf("Failed to create followup message: %v\n", err)
-						return
-					}
-				}
+			// send the text split to max 2000 letter messages, with buttons for changing it
+			messageIDs := sendGeneratedText(s, i, msg+"\n\n"+generatedText)
+			if len(messageIDs) == 0 {
+				return
+			}
+
+			// the buttons need the options the text was generated with
+			if user := interactionUser(i); user != nil {
+				addGeneration(&generation{
+					ModelID:    wordModel.ID,
+					Words:      amountOfWords,
+					UserID:     user.ID,
+					Texts:      []string{generatedText},
+					MessageIDs: messageIDs,
+					Created:    time.Now(),
+				})
 			}
 		},
+		// handler for the hurabot admin command
+		"hurabot": hurabotCommandHandler,
+		// handler for the Reply as model message command
+		"Reply as model": replyAsModelHandler,
+	}
+	// map of message component handlers by the custom ID before the first colon
+	componentHandlers = map[string]func(s botSession, i *discordgo.InteractionCreate){
+		replyModelSelectID: replyModelSelectHandler,
+		generationButtonID: generationButtonHandler,
+	}
+	// map of autocomplete handlers
+	autocompleteHandlers = map[string]func(s botSession, i *discordgo.InteractionCreate){
+		"generate-text": func(s botSession, i *discordgo.InteractionCreate) {
+			modelAutocompleteHandler(s, i, func(model *botModel) bool {
+				return modelUsable(model, i)
+			})
+		},
+		// only the admins who can manage models get choices, & only of the models they could use where they are
+		"hurabot": func(s botSession, i *discordgo.InteractionCreate) {
+			admin := canManageBot(i)
+			options := i.ApplicationCommandData().Options
+			enabling := len(options) > 0 && options[0].Name == "enable"
+
+			modelAutocompleteHandler(s, i, func(model *botModel) bool {
+				if admin == false {
+					return false
+				}
+				// disabled models are the ones to enable
+				if enabling {
+					return canUseModel(model.Info.ID, i) && guildHasModel(i.GuildID, model)
+				}
+				return modelUsable(model, i)
+			})
+		},
 	}
 )
 
@@ -144,147 +430,237 @@ func RunBot() error {
 	if err != nil {
 		return errors.New("error starting bot: failed to open log file " + err.Error())
 	}
-	logMultiWriter := io.MultiWriter(logFile, os.Stdout)
-	logger = log.New(logMultiWriter, "", log.Flags())
-
-	// read the model directory contents & load the models found
-	// check if models are set in config
-	if len(LoadedConfig.ModelsToUse) > 0 {
-		for i := range LoadedConfig.ModelsToUse {
-			var modelFile *os.File
-
-			modelFile, err = os.Open(path.Clean(LoadedConfig.ModelsToUse[i]))
-
-			if err != nil {
-				// try to find in config models dir
-				modelFile, err = os.Open(path.Join(LoadedConfig.ModelDirectory, LoadedConfig.ModelsToUse[i]))
+	logWriter := &logFileWriter{file: logFile}
+	logMultiWriter := io.MultiWriter(logWriter, os.Stdout)
+	logger, err = newLogger(logMultiWriter)
+	if err != nil {
+		logWriter.Close()
+		return errors.New("error starting bot: " + err.Error())
+	}
 
-				if err != nil {
-					logger.Printf("Failed to load model file %s: %v\n", LoadedConfig.ModelsToUse[i], err)
-					continue
-				}
-			}
+	botStartTime = time.Now()
 
-			wordModel, err := LoadModel(modelFile)
-			if err != nil {
-				logger.Printf("Failed to load model from file %s: %v\n", modelFile.Name(), err)
-			}
+	// models are loaded when they're used, keep as many in memory as the config allows
+	wordModelCache = newModelCache(int64(LoadedConfig.ModelCacheSize) * 1024 * 1024)
 
-			logger.Printf("Loaded %d words from model %s\n", len(wordModel.Words), wordModel.Name)
+	initRateLimiters()
 
-			if err := modelFile.Close(); err != nil {
-				logger.Printf("Failed to close model file %s: %v", modelFile.Name(), err)
-			}
+	// load the users who have opted out
+	consentFile, err := DefaultConsentFile()
+	if err != nil {
+		return errors.New("error starting bot: " + err.Error())
+	}
+	consentRegistry, err = LoadConsentRegistry(consentFile)
+	if err != nil {
+		return errors.New("error starting bot: " + err.Error())
+	}
 
-			wordModels = append(wordModels, wordModel)
-		}
-	} else {
-		// Load whole directory
-		modelDirectoryContents, err := os.ReadDir(LoadedConfig.ModelDirectory)
+	// load the times of the scheduled posts
+	stateFile, err := DefaultScheduleStateFile()
+	if err != nil {
+		return errors.New("error starting bot: " + err.Error())
+	}
+	scheduleTimes, err := loadScheduleState(stateFile)
+	if err != nil {
+		return errors.New("error starting bot: " + err.Error())
+	}
 
+	// load the usage counts
+	if LoadedConfig.UsageStats.Enabled {
+		usageStore, err = loadUsageStore()
 		if err != nil {
-			return errors.New("Failed to read model folder " + LoadedConfig.ModelDirectory + ": " + err.Error())
+			return errors.New("error starting bot: " + err.Error())
 		}
+	}
 
-		for _, file := range modelDirectoryContents {
-			modelFile, err := os.Open(LoadedConfig.ModelDirectory + file.Name())
-			if err != nil {
-				return errors.New("failed to open model " + file.Name() + ": " + err.Error())
-			}
-			wordModel, err := LoadModel(modelFile)
-			if err != nil {
-				return errors.New("failed to decode model " + modelFile.Name() + ": " + err.Error())
-			}
-			logger.Printf("Loaded %d words from model %s\n", len(wordModel.Words), wordModel.Name)
-			wordModels = append(wordModels, wordModel)
+	// load the model that learns from new messages
+	if LoadedConfig.LiveModel.Enabled {
+		liveModel, err = loadLiveModel()
+		if err != nil {
+			return errors.New("error starting bot: " + err.Error())
 		}
 	}
 
-	// check that some models were loaded
-	if len(wordModels) < 1 {
-		return errors.New("no word models were loaded")
+	// read the model directory contents & load the info of the models found
+	if _, err := loadModels(); err != nil {
+		return errors.New("error starting bot: " + err.Error())
 	}
 
-	// generate the model option choices from loaded models & update the variable
-	for i, model := range wordModels {
-		textGenerationModelChoice := &discordgo.ApplicationCommandOptionChoice{
-			Name:  model.Name,
-			Value: i,
-		}
-		generateTextModelChoices = append(generateTextModelChoices, textGenerationModelChoice)
+	// check that some models were found
+	if len(botModels) < 1 {
+		return errors.New("no word models were loaded")
 	}
 
-	// we know that text-generate command and the model option are both index 0
-	botCommands[0].Options[0].Choices = generateTextModelChoices
-
-	// also set the max amount of words from config
-	botCommands[0].Options[1].MaxValue = float64(LoadedConfig.MaxWords)
-
-	logger.Printf("%d models loaded in total\n", len(wordModels))
+	logger.Info("Models found", "models", len(botModels))
 
 	// initialize the bot
-	logger.Println("Bot starting")
+	logger.Info("Bot starting")
 	bot, err := discordgo.New("Bot " + LoadedConfig.AuthenticationToken)
 
 	if err != nil {
 		return errors.New("failed to create bot: " + err.Error())
 	}
 
-	logger.Println("Adding handlers")
+	// count the failed API requests for the metrics
+	bot.Client.Transport = apiErrorTransport{next: http.DefaultTransport}
+
+	// cancelled when the bot shuts down, set before any handler can run
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	botContext = ctx
+
+	logger.Debug("Adding handlers")
 	bot.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-		if h, ok := commandHandlers[i.ApplicationCommandData().Name]; ok {
-			h(s, i)
-		}
+		interactionHandler(discordSession{s}, i)
 	})
 
+	// reading messages needs the privileged message content intent
+	if LoadedConfig.Passive.Enabled || LoadedConfig.LiveModel.Enabled {
+		bot.Identify.Intents |= discordgo.IntentsGuildMessages | discordgo.IntentsMessageContent
+	}
+	if LoadedConfig.Passive.Enabled {
+		bot.AddHandler(func(s *discordgo.Session, m *discordgo.MessageCreate) {
+			passiveMessageHandler(discordSession{s}, m)
+		})
+	}
+	if LoadedConfig.LiveModel.Enabled {
+		bot.AddHandler(liveMessageHandler)
+	}
+
 	bot.AddHandler(func(s *discordgo.Session, r *discordgo.Ready) {
-		logger.Printf("Logged in as %s#%s\n", s.State.User.Username, s.State.User.Discriminator)
+		atomic.StoreInt32(&gatewayConnected, 1)
+		logger.Info("Logged in", "user", s.State.User.Username+"#"+s.State.User.Discriminator)
+	})
+	bot.AddHandler(func(s *discordgo.Session, c *discordgo.Connect) {
+		if atomic.AddInt64(&gatewayConnects, 1) > 1 {
+			logger.Info("Reconnected to the gateway")
+		}
+	})
+
+	bot.AddHandler(func(s *discordgo.Session, c *discordgo.Disconnect) {
+		atomic.StoreInt32(&gatewayConnected, 0)
+	})
+	bot.AddHandler(func(s *discordgo.Session, r *discordgo.Resumed) {
+		atomic.StoreInt32(&gatewayConnected, 1)
 	})
 
+	// serve the metrics & health checks if their addresses are set
+	httpServers, err := startHTTPServers()
+	if err != nil {
+		return errors.New("error starting bot: " + err.Error())
+	}
+	defer func() {
+		for _, server := range httpServers {
+			server.Close()
+		}
+	}()
+
 	if err := bot.Open(); err != nil {
 		return fmt.Errorf("cannot open the session: %v", err)
 	}
 
-	// register the commands from botCommands
-	logger.Println("Adding commands...")
-	registeredCommands := make([]*discordgo.ApplicationCommand, len(botCommands))
-	for i, v := range botCommands {
-		cmd, err := bot.ApplicationCommandCreate(bot.State.User.ID, LoadedConfig.GuildID, v)
-		if err != nil {
-			logger.Printf("Cannot create command %s: %v", v.Name, err)
+	// register the commands from botCommands to every guild with the guild's settings
+	logger.Info("Adding commands")
+	if LoadedConfig.PersistentCommands {
+		if err := syncCommands(discordSession{bot}); err != nil {
+			logger.Error("Failed to register commands", "error", err)
 		}
-		registeredCommands[i] = cmd
+	} else {
+		for _, v := range botCommands {
+			_ = registerCommand(discordSession{bot}, v)
+		}
+	}
+
+	session := discordSession{bot}
+
+	// reload models when they change
+	go watchModels(ctx.Done())
+
+	// post the scheduled messages
+	go runScheduler(session, scheduleTimes, ctx.Done())
+
+	// save the usage counts periodically
+	if usageStore != nil {
+		go runUsageStats(ctx.Done())
 	}
 
-	defer bot.Close()
+	// save the live model periodically
+	if liveModel != nil {
+		go runLiveModel(ctx.Done())
+	}
 
-	// shutdown bot after Ctrl+C is received
+	// shutdown bot after Ctrl+C or SIGTERM from a service manager is received
 	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt)
-	log.Println("Press Ctrl+C to exit")
-	<-stop
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	logger.Info("Press Ctrl+C to exit")
+	received := <-stop
+	signal.Stop(stop)
 
-	// remove the commands & shut down
-	logger.Println("Removing commands...")
-	registeredCommands, err = bot.ApplicationCommands(bot.State.User.ID, LoadedConfig.GuildID)
-	if err != nil {
-		logger.Fatalf("Could not fetch registered commands: %v", err)
+	logger.Info("Shutting down", "signal", received.String())
+
+	shutdownErr := shutdownBot(session, cancel)
+	if shutdownErr != nil {
+		logger.Error("Failed to shut down cleanly", "error", shutdownErr)
 	}
 
-	for _, v := range registeredCommands {
-		err := bot.ApplicationCommandDelete(bot.State.User.ID, LoadedConfig.GuildID, v.ID)
-		if err != nil {
-			logger.Panicf("Cannot delete '%v' command: %v", v.Name, err)
+	if err := bot.Close(); err != nil {
+		logger.Error("Failed to close the session", "error", err)
+	}
+
+	logger.Info("Gracefully shutting down")
+
+	// commands that didn't finish in time can still log, only to stdout
+	if err := logWriter.Close(); err != nil {
+		return err
+	}
+	return shutdownErr
+}
+
+// interactionHandler runs the handler of a command, message component or autocomplete interaction
+func interactionHandler(s botSession, i *discordgo.InteractionCreate) {
+	if startTask() == false {
+		if i.Type == discordgo.InteractionApplicationCommand {
+			respondEphemeral(s, i, "The bot is shutting down, try again later")
 		}
+		return
 	}
+	defer finishTask()
+
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		countCommand(i.ApplicationCommandData().Name, i.GuildID)
 
-	logger.Println("Gracefully shutting down.")
+		if h, ok := commandHandlers[i.ApplicationCommandData().Name]; ok {
+			// admin commands & opting out work everywhere
+			if i.ApplicationCommandData().Name != "hurabot" && channelAllowed(i) == false {
+				respondEphemeral(s, i, "Commands can't be used in this channel")
+				return
+			}
+
+			if allowed, wait := checkRateLimits(i); allowed == false {
+				logger.Info("Rate limited command", "command", i.ApplicationCommandData().Name, "channel", i.ChannelID)
+				respondRateLimited(s, i, wait)
+				return
+			}
 
-	logger = nil
-	if err := logFile.Close(); err != nil {
-		return fmt.Errorf("failed to close log file %s: %v", logFile.Name(), err)
+			atomic.AddInt64(&commandsHandled, 1)
+			h(s, i)
+		}
+	case discordgo.InteractionMessageComponent:
+		handlerID, _, _ := strings.Cut(i.MessageComponentData().CustomID, ":")
+		if h, ok := componentHandlers[handlerID]; ok {
+			if allowed, wait := checkRateLimits(i); allowed == false {
+				respondRateLimited(s, i, wait)
+				return
+			}
+
+			h(s, i)
+		}
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		if h, ok := autocompleteHandlers[i.ApplicationCommandData().Name]; ok {
+			h(s, i)
+		}
 	}
-	return nil
 }
 
 // openLog opens a log file in the directory of MainBotConfig for writing
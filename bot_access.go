@@ -0,0 +1,104 @@
+package main
+
+import (
+	"github.com/bwmarrin/discordgo"
+)
+
+// ModelAccess who can use a model. Denied IDs always win, and if any allowed IDs are set
+// the model can only be used when at least one of them matches
+type ModelAccess struct {
+	// Guilds where the model can be used
+	AllowedGuildIDs []string
+	// Channels where the model can be used
+	AllowedChannelIDs []string
+	// Roles that can use the model
+	AllowedRoleIDs []string
+	// Users that can use the model
+	AllowedUserIDs []string
+	// Guilds where the model can't be used
+	DeniedGuildIDs []string
+	// Channels where the model can't be used
+	DeniedChannelIDs []string
+	// Roles that can't use the model
+	DeniedRoleIDs []string
+	// Users that can't use the model
+	DeniedUserIDs []string
+}
+
+// allows checks if a user with the given roles can use the model in a channel & guild, the guild is empty in DMs
+func (access ModelAccess) allows(userID string, channelID string, guildID string, roleIDs []string) bool {
+	if containsID(access.DeniedUserIDs, userID) || containsID(access.DeniedChannelIDs, channelID) ||
+		containsID(access.DeniedGuildIDs, guildID) {
+		return false
+	}
+
+	for _, roleID := range roleIDs {
+		if containsID(access.DeniedRoleIDs, roleID) {
+			return false
+		}
+	}
+
+	// everyone is allowed if the allowed lists are empty
+	if len(access.AllowedUserIDs) == 0 && len(access.AllowedChannelIDs) == 0 &&
+		len(access.AllowedGuildIDs) == 0 && len(access.AllowedRoleIDs) == 0 {
+		return true
+	}
+
+	if containsID(access.AllowedUserIDs, userID) || containsID(access.AllowedChannelIDs, channelID) ||
+		containsID(access.AllowedGuildIDs, guildID) {
+		return true
+	}
+
+	for _, roleID := range roleIDs {
+		if containsID(access.AllowedRoleIDs, roleID) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// containsID checks if an ID is in a list of IDs, empty IDs never match
+func containsID(ids []string, id string) bool {
+	if id == "" {
+		return false
+	}
+
+	for _, listID := range ids {
+		if listID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// canUseModel checks if the user of an interaction can use a model where the interaction was sent
+func canUseModel(modelID string, i *discordgo.InteractionCreate) bool {
+	userID := ""
+	if user := interactionUser(i); user != nil {
+		userID = user.ID
+	}
+
+	var roleIDs []string
+	if i.Member != nil {
+		roleIDs = i.Member.Roles
+	}
+
+	return canUseModelAs(modelID, userID, i.ChannelID, i.GuildID, roleIDs)
+}
+
+// canUseModelAs checks if a user with the given roles can use a model in a channel & guild
+func canUseModelAs(modelID string, userID string, channelID string, guildID string, roleIDs []string) bool {
+	access, ok := LoadedConfig.ModelAccess[modelID]
+	if ok == false {
+		return true
+	}
+
+	return access.allows(userID, channelID, guildID, roleIDs)
+}
+
+// modelUsable checks if a model can be used for generating text by the user of an interaction where it was sent
+func modelUsable(model *botModel, i *discordgo.InteractionCreate) bool {
+	return isModelDisabled(model.Info.ID) == false && canUseModel(model.Info.ID, i) &&
+		guildHasModel(i.GuildID, model) && hasOptedOutContributors(model) == false
+}
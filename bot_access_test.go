@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestModelAccess(t *testing.T) {
+	// a model only for the private guild or its owner, but never in the general channel
+	access := ModelAccess{
+		AllowedGuildIDs:  []string{"private guild"},
+		AllowedUserIDs:   []string{"owner"},
+		DeniedChannelIDs: []string{"general"},
+		DeniedRoleIDs:    []string{"muted"},
+	}
+
+	tests := []struct {
+		name      string
+		userID    string
+		channelID string
+		guildID   string
+		roleIDs   []string
+		allowed   bool
+	}{
+		{"private guild", "user", "channel", "private guild", nil, true},
+		{"other guild", "user", "channel", "other guild", nil, false},
+		{"owner in other guild", "owner", "channel", "other guild", nil, true},
+		{"owner in DM", "owner", "dm", "", nil, true},
+		{"user in DM", "user", "dm", "", nil, false},
+		{"denied channel", "owner", "general", "private guild", nil, false},
+		{"denied role", "user", "channel", "private guild", []string{"member", "muted"}, false},
+	}
+
+	for _, test := range tests {
+		if allowed := access.allows(test.userID, test.channelID, test.guildID, test.roleIDs); allowed != test.allowed {
+			t.Errorf("%s: expected allowed to be %t, got %t", test.name, test.allowed, allowed)
+		}
+	}
+
+	// without allowed IDs everyone who isn't denied can use the model
+	access = ModelAccess{DeniedUserIDs: []string{"spammer"}}
+
+	if access.allows("user", "channel", "", nil) == false {
+		t.Error("user was denied without allowed IDs")
+	}
+	if access.allows("spammer", "channel", "", nil) == true {
+		t.Error("denied user was allowed")
+	}
+
+	// roles are enough when they are allowed
+	access = ModelAccess{AllowedRoleIDs: []string{"friends"}}
+
+	if access.allows("user", "channel", "guild", []string{"friends"}) == false {
+		t.Error("user with an allowed role was denied")
+	}
+}
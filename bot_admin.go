@@ -0,0 +1,438 @@
+package main
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// time the bot was started
+	botStartTime = time.Now()
+	// amount of commands handled since the bot was started
+	commandsHandled int64
+	// amount of words generated since the bot was started
+	wordsGenerated int64
+
+	// lock for disabledModels & changing the config at runtime
+	adminMutex sync.RWMutex
+	// IDs of the models disabled with the admin command
+	disabledModels = make(map[string]bool)
+	// admin subcommands that only change the guild they are used in, the admin roles of a guild can use them
+	guildAdminSubcommands = map[string]bool{"maxwords": true, "stats": true}
+)
+
+// hurabotCommandHandler handler for the hurabot admin command, runs the subcommand given
+func hurabotCommandHandler(s botSession, i *discordgo.InteractionCreate) {
+	options := i.ApplicationCommandData().Options
+
+	if len(options) < 1 {
+		return
+	}
+
+	subcommand := options[0]
+
+	// anyone can opt out
+	if subcommand.Name == "optout" {
+		hurabotOptOut(s, i)
+		return
+	}
+
+	if isBotAdmin(i) == false {
+		logger.Warn("Denied admin command from non-admin user", "subcommand", subcommand.Name, "user_id", interactionUser(i).ID)
+		respondEphemeral(s, i, adminDeniedMessage("Only admins can use this command"))
+		return
+	}
+
+	// admin roles of a guild only manage the guild, the other subcommands change the bot in every guild
+	if guildAdminSubcommands[subcommand.Name] == false && canManageBot(i) == false {
+		logger.Warn("Denied admin command from guild admin", "subcommand", subcommand.Name, "user_id", interactionUser(i).ID)
+		respondEphemeral(s, i, adminDeniedMessage("Only the admins of the bot can use this command"))
+		return
+	}
+
+	logger.Info("Received admin command", "subcommand", subcommand.Name, "user", interactionUser(i).Username, "options", optionValues(subcommand.Options))
+
+	switch subcommand.Name {
+	case "prune":
+		hurabotPrune(s, i, subcommand.Options)
+	case "list":
+		hurabotList(s, i)
+	case "reload":
+		hurabotReload(s, i)
+	case "enable", "disable":
+		hurabotSetModelEnabled(s, i, subcommand.Options, subcommand.Name == "enable")
+	case "maxwords":
+		hurabotMaxWords(s, i, subcommand.Options)
+	case "stats":
+		hurabotStats(s, i, subcommand.Options)
+	case "schedule":
+		hurabotSchedule(s, i, subcommand.Options)
+	case "fetch":
+		hurabotFetch(s, i, subcommand.Options)
+	}
+}
+
+// hurabotList lists the models found & whether they are enabled
+func hurabotList(s botSession, i *discordgo.InteractionCreate) {
+	modelsMutex.RLock()
+	models := append([]*botModel(nil), botModels...)
+	modelsMutex.RUnlock()
+
+	var list strings.Builder
+	fmt.Fprintf(&list, "%d models found:\n", len(models))
+
+	for _, model := range models {
+		status := ""
+		if isModelDisabled(model.Info.ID) {
+			status = " (disabled)"
+		} else if hasOptedOutContributors(model) {
+			status = " (has opted out users)"
+		}
+		fmt.Fprintf(&list, "`%s` %s%s\n", model.Info.ID, model.Info.Name, status)
+	}
+
+	// long lists are cut to fit a single message
+	respondEphemeral(s, i, splitText(list.String())[0])
+}
+
+// hurabotReload reloads changed models
+func hurabotReload(s botSession, i *discordgo.InteractionCreate) {
+	if err := reloadModels(); err != nil {
+		logger.Error("Failed to reload models", "error", err)
+		respondEphemeral(s, i, "Failed to reload models: "+err.Error())
+		return
+	}
+
+	modelsMutex.RLock()
+	modelCount := len(botModels)
+	modelsMutex.RUnlock()
+
+	respondEphemeral(s, i, fmt.Sprintf("Models reloaded, %d models found", modelCount))
+}
+
+// hurabotSetModelEnabled enables or disables a model until the bot is restarted
+func hurabotSetModelEnabled(s botSession, i *discordgo.InteractionCreate,
+	options []*discordgo.ApplicationCommandInteractionDataOption, enabled bool) {
+	model := getModel(options[0].StringValue())
+	if model == nil {
+		respondEphemeral(s, i, "Unknown model "+options[0].StringValue())
+		return
+	}
+
+	adminMutex.Lock()
+	if enabled {
+		delete(disabledModels, model.Info.ID)
+	} else {
+		disabledModels[model.Info.ID] = true
+	}
+	adminMutex.Unlock()
+
+	if enabled {
+		logger.Info("Enabled model", "model", model.Info.ID)
+		respondEphemeral(s, i, "Enabled model "+model.Info.Name)
+	} else {
+		logger.Info("Disabled model", "model", model.Info.ID)
+		respondEphemeral(s, i, "Disabled model "+model.Info.Name)
+	}
+}
+
+// hurabotMaxWords changes the maximum amount of words of the guild, or the global one if the guild has no
+// settings of its own, until the bot is restarted & registers generate-text again
+func hurabotMaxWords(s botSession, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption) {
+	newMaxWords := int(options[0].IntValue())
+	if newMaxWords < 1 {
+		respondEphemeral(s, i, "Maximum amount of words has to be at least 1")
+		return
+	}
+
+	adminMutex.Lock()
+	if settings, ok := LoadedConfig.Guilds[i.GuildID]; ok {
+		settings.MaxWords = newMaxWords
+		LoadedConfig.Guilds[i.GuildID] = settings
+	} else {
+		LoadedConfig.MaxWords = newMaxWords
+	}
+	adminMutex.Unlock()
+
+	logger.Info("Changed maximum amount of words", "guild", i.GuildID, "words", newMaxWords)
+
+	// we know that text-generate command is index 0
+	if err := registerCommand(s, botCommands[0]); err != nil {
+		respondEphemeral(s, i, fmt.Sprintf("Maximum amount of words changed to %d, but updating the command failed: %v", newMaxWords, err))
+		return
+	}
+
+	respondEphemeral(s, i, fmt.Sprintf("Maximum amount of words changed to %d", newMaxWords))
+}
+
+// hurabotStats shows the uptime & statistics of the bot, and the usage leaderboards if usage is counted.
+// Only the usage of the guild is shown, except to the users in AdminUserIDs
+func hurabotStats(s botSession, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption) {
+	days := 7
+	if len(options) > 0 {
+		days = int(options[0].IntValue())
+	}
+	if days < 1 || days > 365 {
+		respondEphemeral(s, i, "Days has to be between 1 and 365")
+		return
+	}
+
+	modelsMutex.RLock()
+	modelCount := len(botModels)
+	modelsMutex.RUnlock()
+
+	adminMutex.RLock()
+	disabledCount := len(disabledModels)
+	adminMutex.RUnlock()
+
+	cachedCount, cachedBytes := wordModelCache.stats()
+
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	stats := fmt.Sprintf("Uptime: %s\n"+
+		"Models: %d found, %d disabled, %d loaded using about %.1f MB\n"+
+		"Commands handled: %d\n"+
+		"Words generated: %d\n"+
+		"Memory in use: %.1f MB",
+		time.Since(botStartTime).Round(time.Second), modelCount, disabledCount, cachedCount,
+		float64(cachedBytes)/1024/1024, atomic.LoadInt64(&commandsHandled), atomic.LoadInt64(&wordsGenerated),
+		float64(memStats.Alloc)/1024/1024)
+
+	if usageStore != nil {
+		guildID := i.GuildID
+		if isGlobalAdmin(i) {
+			guildID = ""
+		}
+		stats += "\n\n" + usageStats(days, guildID, time.Now())
+	}
+
+	// messages can be at most 2000 characters
+	respondEphemeral(s, i, truncateText(stats, 2000))
+}
+
+// isModelDisabled checks if a model was disabled with the admin command
+func isModelDisabled(id string) bool {
+	adminMutex.RLock()
+	defer adminMutex.RUnlock()
+
+	return disabledModels[id]
+}
+
+// hurabotPrune removes words, phrases or messages from a loaded model & rewrites its file
+func hurabotPrune(s botSession, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption) {
+	optionMap := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(options))
+	for _, opt := range options {
+		optionMap[opt.Name] = opt
+	}
+
+	model := getModel(optionMap["model"].StringValue())
+	if model == nil {
+		respondEphemeral(s, i, "Unknown model "+optionMap["model"].StringValue())
+		return
+	}
+
+	// parse the prune options
+	pruneOptions := PruneOptions{}
+
+	if option, ok := optionMap["phrase"]; ok {
+		pruneOptions.Phrases = append(pruneOptions.Phrases, option.StringValue())
+	}
+
+	if option, ok := optionMap["regex"]; ok {
+		pattern, err := regexp.Compile(option.StringValue())
+		if err != nil {
+			respondEphemeral(s, i, "Invalid regular expression: "+err.Error())
+			return
+		}
+		pruneOptions.Patterns = append(pruneOptions.Patterns, pattern)
+	}
+
+	if option, ok := optionMap["channel"]; ok {
+		channelID, err := strconv.Atoi(option.StringValue())
+		if err != nil {
+			respondEphemeral(s, i, "Invalid channel ID: "+option.StringValue())
+			return
+		}
+		pruneOptions.ChannelIDs = append(pruneOptions.ChannelIDs, channelID)
+	}
+
+	if option, ok := optionMap["from"]; ok {
+		from, err := ParsePruneDate(option.StringValue())
+		if err != nil {
+			respondEphemeral(s, i, "Invalid date, use the format YYYY-MM-DD: "+option.StringValue())
+			return
+		}
+		pruneOptions.From = from
+	}
+
+	if option, ok := optionMap["until"]; ok {
+		until, err := ParsePruneDate(option.StringValue())
+		if err != nil {
+			respondEphemeral(s, i, "Invalid date, use the format YYYY-MM-DD: "+option.StringValue())
+			return
+		}
+		// include the whole day
+		pruneOptions.Until = until.AddDate(0, 0, 1)
+	}
+
+	if len(pruneOptions.Phrases) == 0 && len(pruneOptions.Patterns) == 0 && pruneOptions.removesMessages() == false {
+		respondEphemeral(s, i, "Nothing to prune, give a phrase, regular expression, channel or dates to remove")
+		return
+	}
+
+	// loading, pruning & saving can take a while with big models
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: uint64(discordgo.MessageFlagsEphemeral),
+		},
+	}); err != nil {
+		logger.Error("Failed to send interaction response", "error", err)
+		return
+	}
+
+	wordModel, err := model.load()
+	if err != nil {
+		logger.Error("Failed to load model", "model", model.Info.ID, "error", err)
+		if _, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: "Failed to load model " + model.Info.Name,
+		}); err != nil {
+			logger.Error("Failed to edit message", "error", err)
+		}
+		return
+	}
+
+	// prune a copy so the loaded model stays usable if something goes wrong
+	prunedModel := wordModel.Copy()
+
+	result, err := PruneModel(prunedModel, pruneOptions)
+
+	var msg string
+
+	switch {
+	case err != nil:
+		msg = "Failed to prune model: " + err.Error()
+	case result.Words == 0 && result.Messages == 0:
+		msg = "Nothing to remove from model " + wordModel.Name
+	case len(prunedModel.Words) < 1:
+		msg = "Not pruning, no words would be left in model " + wordModel.Name
+	default:
+		if err := SaveModel(prunedModel, wordModel.filePath); err != nil {
+			logger.Error("Failed to save pruned model", "model", wordModel.ID, "error", err)
+			msg = "Failed to save pruned model: " + err.Error()
+			break
+		}
+
+		wordModel.mutex.Lock()
+		wordModel.Words = prunedModel.Words
+		wordModel.Messages = prunedModel.Messages
+		wordModel.Contributors = prunedModel.Contributors
+		wordModel.mutex.Unlock()
+
+		logger.Info("Pruned model", "model", wordModel.ID, "messages", result.Messages, "words", result.Words)
+		msg = fmt.Sprintf("Removed %d messages and %d words from model %s, %d words left",
+			result.Messages, result.Words, wordModel.Name, len(prunedModel.Words))
+	}
+
+	if _, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: msg,
+	}); err != nil {
+		logger.Error("Failed to edit message", "error", err)
+	}
+}
+
+// isBotAdmin checks if the user of an interaction is allowed to use admin commands in the guild of the interaction
+func isBotAdmin(i *discordgo.InteractionCreate) bool {
+	if canManageBot(i) {
+		return true
+	}
+
+	// guilds can have admin roles of their own
+	return i.Member != nil && hasRole(i.Member, guildSettings(i.GuildID).AdminRoleIDs)
+}
+
+// canManageBot checks if the user of an interaction is allowed to use the admin commands that change the bot in
+// every guild. If no admins are set in the config, server admins are allowed when the commands are only in GuildID
+func canManageBot(i *discordgo.InteractionCreate) bool {
+	if user := interactionUser(i); user != nil && containsID(LoadedConfig.AdminUserIDs, user.ID) {
+		return true
+	}
+
+	if i.Member != nil && hasRole(i.Member, LoadedConfig.AdminRoleIDs) {
+		return true
+	}
+
+	// server admins of any guild could use commands registered globally or in several guilds
+	if serverAdminsAllowed() && i.GuildID == LoadedConfig.GuildID {
+		return i.Member != nil && i.Member.Permissions&discordgo.PermissionAdministrator != 0
+	}
+
+	return false
+}
+
+// botAdminsSet checks if any admin users or roles are set in the config
+func botAdminsSet() bool {
+	return len(LoadedConfig.AdminUserIDs) > 0 || len(LoadedConfig.AdminRoleIDs) > 0
+}
+
+// serverAdminsAllowed checks if server admins can manage the bot, only when no admins are set & the commands are
+// registered only in GuildID
+func serverAdminsAllowed() bool {
+	return botAdminsSet() == false && LoadedConfig.GuildID != "" && len(LoadedConfig.Guilds) == 0
+}
+
+// adminDeniedMessage returns the message for a denied admin command, telling how to set admins if there are none
+func adminDeniedMessage(message string) string {
+	if botAdminsSet() == false && serverAdminsAllowed() == false {
+		return "No admins are set for the bot, add AdminUserIDs or AdminRoleIDs to the config to use this command"
+	}
+	return message
+}
+
+// hasRole checks if a member has any of the roles
+func hasRole(member *discordgo.Member, roleIDs []string) bool {
+	for _, roleID := range member.Roles {
+		if containsID(roleIDs, roleID) {
+			return true
+		}
+	}
+	return false
+}
+
+// interactionUser returns the user of an interaction sent from a guild or a DM
+func interactionUser(i *discordgo.InteractionCreate) *discordgo.User {
+	if i.Member != nil {
+		return i.Member.User
+	}
+	return i.User
+}
+
+// optionValues returns the values of command options by their names for logging
+func optionValues(options []*discordgo.ApplicationCommandInteractionDataOption) map[string]interface{} {
+	values := make(map[string]interface{}, len(options))
+	for _, option := range options {
+		values[option.Name] = option.Value
+	}
+	return values
+}
+
+// respondEphemeral responds to an interaction with a message only the user can see
+func respondEphemeral(s botSession, i *discordgo.InteractionCreate, content string) {
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   uint64(discordgo.MessageFlagsEphemeral),
+		},
+	}); err != nil {
+		logger.Error("Failed to send interaction response", "error", err)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"sort"
+	"strings"
+)
+
+// guildCommands returns the commands of the bot with the settings of a guild
+func guildCommands(guildID string) []*discordgo.ApplicationCommand {
+	commands := make([]*discordgo.ApplicationCommand, 0, len(botCommands))
+	for _, command := range botCommands {
+		commands = append(commands, guildCommand(command, guildID))
+	}
+	return commands
+}
+
+// isBotCommand checks if a registered command is one of the bot's commands
+func isBotCommand(command *discordgo.ApplicationCommand) bool {
+	for _, botCommand := range botCommands {
+		if commandType(botCommand) == commandType(command) && botCommand.Name == command.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// commandType returns the type of a command, commands without a type are chat commands
+func commandType(command *discordgo.ApplicationCommand) discordgo.ApplicationCommandType {
+	if command.Type == 0 {
+		return discordgo.ChatApplicationCommand
+	}
+	return command.Type
+}
+
+// normalizeOptions copies options leaving out what Discord adds or leaves out when returning commands
+func normalizeOptions(options []*discordgo.ApplicationCommandOption) []*discordgo.ApplicationCommandOption {
+	if len(options) == 0 {
+		return nil
+	}
+
+	normalized := make([]*discordgo.ApplicationCommandOption, 0, len(options))
+	for _, option := range options {
+		optionCopy := *option
+		optionCopy.NameLocalizations = nil
+		optionCopy.DescriptionLocalizations = nil
+		optionCopy.Options = normalizeOptions(option.Options)
+
+		if len(option.ChannelTypes) == 0 {
+			optionCopy.ChannelTypes = nil
+		}
+		if len(option.Choices) == 0 {
+			optionCopy.Choices = nil
+		}
+
+		normalized = append(normalized, &optionCopy)
+	}
+	return normalized
+}
+
+// commandSignature returns the parts of a command that are compared when checking if it has changed
+func commandSignature(command *discordgo.ApplicationCommand) string {
+	signature, _ := json.Marshal(discordgo.ApplicationCommand{
+		Type:        commandType(command),
+		Name:        command.Name,
+		Description: command.Description,
+		Options:     normalizeOptions(command.Options),
+	})
+	return string(signature)
+}
+
+// commandsChanged checks if the registered bot commands differ from the wanted ones. Commands that aren't
+// the bot's are left out of the comparison
+func commandsChanged(registered []*discordgo.ApplicationCommand, wanted []*discordgo.ApplicationCommand) bool {
+	registeredSignatures := make([]string, 0, len(registered))
+	for _, command := range registered {
+		if isBotCommand(command) {
+			registeredSignatures = append(registeredSignatures, commandSignature(command))
+		}
+	}
+
+	wantedSignatures := make([]string, 0, len(wanted))
+	for _, command := range wanted {
+		wantedSignatures = append(wantedSignatures, commandSignature(command))
+	}
+
+	if len(registeredSignatures) != len(wantedSignatures) {
+		return true
+	}
+
+	sort.Strings(registeredSignatures)
+	sort.Strings(wantedSignatures)
+
+	for index := range wantedSignatures {
+		if registeredSignatures[index] != wantedSignatures[index] {
+			return true
+		}
+	}
+	return false
+}
+
+// syncCommands registers the commands to every guild in the config with one request per guild,
+// only if they have changed since they were last registered
+func syncCommands(s botSession) error {
+	failures := make([]string, 0)
+
+	for _, guildID := range commandGuildIDs() {
+		registered, err := s.ApplicationCommands(s.userID(), guildID)
+		if err != nil {
+			logger.Error("Could not fetch registered commands", "guild", guildID, "error", err)
+			failures = append(failures, fmt.Sprintf("fetching commands of guild %s: %v", guildID, err))
+			continue
+		}
+
+		wanted := guildCommands(guildID)
+		if commandsChanged(registered, wanted) == false {
+			logger.Info("Commands are up to date", "guild", guildID)
+			continue
+		}
+
+		// commands of other tools are kept, bulk overwriting would remove them
+		for _, command := range registered {
+			if isBotCommand(command) == false {
+				wanted = append(wanted, command)
+			}
+		}
+
+		if _, err := s.ApplicationCommandBulkOverwrite(s.userID(), guildID, wanted); err != nil {
+			logger.Error("Cannot update commands", "guild", guildID, "error", err)
+			failures = append(failures, fmt.Sprintf("updating commands of guild %s: %v", guildID, err))
+			continue
+		}
+		logger.Info("Updated commands", "guild", guildID)
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to register commands: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// removeCommands removes the bot's commands from every guild, trying every command even if some fail.
+// Other commands of the application are left alone
+func removeCommands(s botSession) error {
+	failures := make([]string, 0)
+
+	for _, guildID := range commandGuildIDs() {
+		registeredCommands, err := s.ApplicationCommands(s.userID(), guildID)
+		if err != nil {
+			logger.Error("Could not fetch registered commands", "guild", guildID, "error", err)
+			failures = append(failures, fmt.Sprintf("fetching commands of guild %s: %v", guildID, err))
+			continue
+		}
+
+		for _, command := range registeredCommands {
+			if isBotCommand(command) == false {
+				continue
+			}
+
+			if err := s.ApplicationCommandDelete(s.userID(), guildID, command.ID); err != nil {
+				logger.Error("Cannot delete command", "command", command.Name, "guild", guildID, "error", err)
+				failures = append(failures, fmt.Sprintf("deleting command %s from guild %s: %v", command.Name, guildID, err))
+			}
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to remove commands: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// CommandsRegister registers the bot's commands to the guilds in the config without running the bot
+func CommandsRegister() error {
+	setCLILogger()
+
+	if LoadedConfig.AuthenticationToken == "" {
+		return fmt.Errorf("authentication token is empty")
+	}
+
+	session, err := newRESTSession(LoadedConfig.AuthenticationToken)
+	if err != nil {
+		return err
+	}
+
+	return syncCommands(session)
+}
+
+// CommandsUnregister removes the bot's commands from the guilds in the config
+func CommandsUnregister() error {
+	setCLILogger()
+
+	if LoadedConfig.AuthenticationToken == "" {
+		return fmt.Errorf("authentication token is empty")
+	}
+
+	session, err := newRESTSession(LoadedConfig.AuthenticationToken)
+	if err != nil {
+		return err
+	}
+
+	if err := removeCommands(session); err != nil {
+		return err
+	}
+
+	logger.Info("Commands removed")
+	return nil
+}
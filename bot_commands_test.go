@@ -0,0 +1,83 @@
+package main
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"testing"
+)
+
+func TestSyncCommands(t *testing.T) {
+	setupBotTest(t, &MainBotConfig{GuildID: "main", MaxWords: 200}, "hello")
+
+	s := newFakeSession()
+	s.commands["main"] = []*discordgo.ApplicationCommand{{ID: "100", Name: "other-tool", Description: "Not ours"}}
+
+	if err := syncCommands(s); err != nil {
+		t.Fatal(err)
+	}
+	if s.overwrites != 1 || len(s.commands["main"]) != len(botCommands)+1 {
+		t.Fatalf("expected the commands to be registered with the other command kept, got %d overwrites & %d commands",
+			s.overwrites, len(s.commands["main"]))
+	}
+
+	// nothing changed, so nothing is sent
+	if err := syncCommands(s); err != nil {
+		t.Fatal(err)
+	}
+	if s.overwrites != 1 {
+		t.Errorf("expected unchanged commands not to be overwritten, got %d overwrites", s.overwrites)
+	}
+
+	LoadedConfig.MaxWords = 100
+	if err := syncCommands(s); err != nil {
+		t.Fatal(err)
+	}
+	if s.overwrites != 2 {
+		t.Errorf("expected changed commands to be overwritten, got %d overwrites", s.overwrites)
+	}
+
+	// only the bot's commands are removed
+	if err := removeCommands(s); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.commands["main"]) != 1 || s.commands["main"][0].Name != "other-tool" {
+		t.Errorf("expected only the other command to be left, got %v", s.commands["main"])
+	}
+}
+
+func TestPersistentCommandsKeptOnShutdown(t *testing.T) {
+	setupBotTest(t, &MainBotConfig{GuildID: "main", MaxWords: 200, PersistentCommands: true}, "hello")
+	cancel := setupShutdownTest(t)
+
+	s := newFakeSession()
+	if err := syncCommands(s); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := shutdownBot(s, cancel); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.commands["main"]) != len(botCommands) {
+		t.Errorf("expected the commands to stay registered, got %d", len(s.commands["main"]))
+	}
+}
+
+func TestCommandsChanged(t *testing.T) {
+	setupBotTest(t, &MainBotConfig{MaxWords: 200}, "hello")
+
+	wanted := guildCommands("")
+	if commandsChanged(nil, wanted) == false {
+		t.Error("missing commands were not noticed")
+	}
+
+	// a command with a changed description is noticed
+	changed := *wanted[0]
+	changed.Description = "Something else"
+	registered := append([]*discordgo.ApplicationCommand{&changed}, wanted[1:]...)
+	if commandsChanged(registered, wanted) == false {
+		t.Error("changed description was not noticed")
+	}
+
+	if commandsChanged(wanted, wanted) == true {
+		t.Error("identical commands were noticed as changed")
+	}
+}
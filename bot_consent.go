@@ -0,0 +1,48 @@
+package main
+
+import (
+	"github.com/bwmarrin/discordgo"
+)
+
+// consentRegistry the users who have opted out of the models
+var consentRegistry *ConsentRegistry
+
+// hurabotOptOut opts the user out of the models, anyone can use it
+func hurabotOptOut(s botSession, i *discordgo.InteractionCreate) {
+	user := interactionUser(i)
+	if user == nil {
+		return
+	}
+
+	optedOut, err := consentRegistry.OptOut(user.ID)
+	if err != nil {
+		logger.Error("Failed to opt out user", "user_id", user.ID, "error", err)
+		respondEphemeral(s, i, "Failed to save your opt out, please try again later")
+		return
+	}
+
+	if optedOut == false {
+		respondEphemeral(s, i, "You have already opted out")
+		return
+	}
+
+	// the usage counts of the user are forgotten too
+	if usageStore != nil {
+		usageStore.RemoveUser(user.ID)
+	}
+
+	// the live model can do without the messages of the user right away
+	if liveModel != nil {
+		if err := removeLiveModelUser(user.ID); err != nil {
+			logger.Error("Failed to remove messages of opted out user from live model", "user_id", user.ID, "error", err)
+		}
+	}
+
+	logger.Info("User opted out of the models", "user_id", user.ID)
+	respondEphemeral(s, i, "You have opted out. Models that contain your messages can't be used until they are made again without them.")
+}
+
+// hasOptedOutContributors checks if a model contains messages from users who have opted out
+func hasOptedOutContributors(model *botModel) bool {
+	return consentRegistry != nil && len(consentRegistry.OptedOutContributors(model.Info.Contributors)) > 0
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// how often the admin is told about the progress of a fetch
+const fetchProgressInterval = 10 * time.Second
+
+var (
+	// lock for runningFetches
+	fetchMutex sync.Mutex
+	// paths of the models being fetched
+	runningFetches = make(map[string]bool)
+)
+
+// hurabotFetch creates a new model from the messages of a channel, the fetch runs in the background
+// & the response is edited with its progress
+func hurabotFetch(s botSession, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption) {
+	var fetchOptions FetchOptions
+	var fileName, name, description string
+
+	for _, option := range options {
+		switch option.Name {
+		case "channel":
+			fetchOptions.ChannelID = option.ChannelValue(nil).ID
+		case "file":
+			fileName = option.StringValue()
+		case "author":
+			fetchOptions.AuthorID = option.UserValue(nil).ID
+		case "limit":
+			fetchOptions.Limit = int(option.IntValue())
+		case "name":
+			name = option.StringValue()
+		case "description":
+			description = option.StringValue()
+		}
+	}
+
+	if fetchOptions.Limit < 0 {
+		respondEphemeral(s, i, "Limit can't be negative")
+		return
+	}
+
+	// models can only be saved to the model directory
+	if fileName == "" || fileName != path.Base(fileName) || strings.HasPrefix(fileName, ".") {
+		respondEphemeral(s, i, "Invalid filename "+fileName)
+		return
+	}
+	if strings.HasSuffix(fileName, ".gob") == false {
+		fileName = fileName + ".gob"
+	}
+	modelPath := path.Join(LoadedConfig.ModelDirectory, fileName)
+
+	if _, err := os.Stat(modelPath); err == nil {
+		respondEphemeral(s, i, "Model "+fileName+" already exists")
+		return
+	}
+
+	// shutting down waits for the fetch to stop
+	if startTask() == false {
+		respondEphemeral(s, i, "The bot is shutting down, try again later")
+		return
+	}
+
+	fetchMutex.Lock()
+	if runningFetches[modelPath] {
+		fetchMutex.Unlock()
+		finishTask()
+		respondEphemeral(s, i, "Model "+fileName+" is already being fetched")
+		return
+	}
+	runningFetches[modelPath] = true
+	fetchMutex.Unlock()
+
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: "Fetching messages of <#" + fetchOptions.ChannelID + "> to model " + fileName,
+			Flags:   uint64(discordgo.MessageFlagsEphemeral),
+		},
+	}); err != nil {
+		logger.Error("Failed to send interaction response", "error", err)
+	}
+
+	go runFetch(s, i, fetchOptions, name, description, modelPath)
+}
+
+// runFetch fetches a model & edits the response of the interaction with the progress
+func runFetch(s botSession, i *discordgo.InteractionCreate, options FetchOptions, name string, description string, modelPath string) {
+	defer func() {
+		fetchMutex.Lock()
+		delete(runningFetches, modelPath)
+		fetchMutex.Unlock()
+		finishTask()
+	}()
+
+	fileName := path.Base(modelPath)
+
+	// the response can't be edited after the interaction token expires, so failed edits are only logged
+	editResponse := func(content string) {
+		if _, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: content,
+		}); err != nil {
+			logger.Error("Failed to edit message", "error", err)
+		}
+	}
+
+	options.Consent = consentRegistry
+	options.Context = botContext
+
+	lastProgress := time.Now()
+	options.Progress = func(kept int) {
+		if time.Since(lastProgress) < fetchProgressInterval {
+			return
+		}
+		lastProgress = time.Now()
+		editResponse(fmt.Sprintf("Fetching messages to model %s, %d messages so far", fileName, kept))
+	}
+	options.RateLimited = func(wait time.Duration) {
+		logger.Warn("Fetching model was rate limited", "file", fileName, "wait", wait)
+	}
+
+	logger.Info("Fetching messages", "channel", options.ChannelID, "file", modelPath)
+
+	wordModel, err := FetchModel(s, options, name, description, modelPath)
+	if err != nil {
+		logger.Error("Failed to fetch model", "file", fileName, "error", err)
+		editResponse("Failed to fetch model " + fileName + ", run the command again to continue from where it stopped: " + err.Error())
+		return
+	}
+
+	logger.Info("Fetched model", "model", wordModel.ID, "messages", len(wordModel.Messages))
+
+	if err := reloadModels(); err != nil {
+		logger.Error("Failed to reload models", "error", err)
+		editResponse(fmt.Sprintf("Saved model %s with %d messages, but failed to reload models: %v",
+			wordModel.Name, len(wordModel.Messages), err))
+		return
+	}
+
+	editResponse(fmt.Sprintf("Saved model %s with %d messages and %d words", wordModel.Name, len(wordModel.Messages), len(wordModel.Words)))
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"path"
+)
+
+// default amount of words generated if the config doesn't set it
+const defaultWordCount = 50
+
+// GuildConfig settings for a single guild, settings left empty use the global ones
+type GuildConfig struct {
+	// IDs or files of the models that can be used in the guild, the models have to be found with the global settings.
+	// Every model can be used if empty
+	ModelsToUse []string
+	// Maximum amount of words that can be generated
+	MaxWords int
+	// Amount of words generated if the amount isn't given
+	DefaultWords int
+	// ID of the model used by Reply as model
+	DefaultModel string
+	// IDs of the channels where the commands can be used, every channel if empty
+	AllowedChannelIDs []string
+	// IDs of the roles that can use the admin commands in the guild, in addition to the global admins
+	AdminRoleIDs []string
+}
+
+// guildSettings returns the settings of a guild with the empty settings filled from the global ones, guildID is empty in DMs
+func guildSettings(guildID string) GuildConfig {
+	adminMutex.RLock()
+	defer adminMutex.RUnlock()
+
+	settings := LoadedConfig.Guilds[guildID]
+
+	if settings.MaxWords <= 0 {
+		settings.MaxWords = LoadedConfig.MaxWords
+	}
+
+	if settings.DefaultWords <= 0 {
+		settings.DefaultWords = LoadedConfig.DefaultWords
+	}
+	if settings.DefaultWords <= 0 {
+		settings.DefaultWords = defaultWordCount
+	}
+	if settings.DefaultWords > settings.MaxWords {
+		settings.DefaultWords = settings.MaxWords
+	}
+
+	if settings.DefaultModel == "" {
+		settings.DefaultModel = LoadedConfig.DefaultModel
+	}
+
+	return settings
+}
+
+// commandGuildIDs returns the IDs of the guilds to register the commands to, an empty ID registers them globally
+func commandGuildIDs() []string {
+	adminMutex.RLock()
+	defer adminMutex.RUnlock()
+
+	if len(LoadedConfig.Guilds) == 0 {
+		return []string{LoadedConfig.GuildID}
+	}
+
+	guildIDs := make([]string, 0, len(LoadedConfig.Guilds)+1)
+	for guildID := range LoadedConfig.Guilds {
+		guildIDs = append(guildIDs, guildID)
+	}
+
+	// the global guild uses the global settings
+	if _, ok := LoadedConfig.Guilds[LoadedConfig.GuildID]; ok == false && LoadedConfig.GuildID != "" {
+		guildIDs = append(guildIDs, LoadedConfig.GuildID)
+	}
+
+	return guildIDs
+}
+
+// guildCommand returns a command with the settings of a guild
+func guildCommand(command *discordgo.ApplicationCommand, guildID string) *discordgo.ApplicationCommand {
+	if command.Name != "generate-text" {
+		return command
+	}
+
+	// copy the words option so every guild can have its own maximum
+	guildCommand := *command
+	guildCommand.Options = append([]*discordgo.ApplicationCommandOption(nil), command.Options...)
+
+	// we know that the words option is index 1
+	wordsOption := *guildCommand.Options[1]
+	wordsOption.MaxValue = float64(guildSettings(guildID).MaxWords)
+	guildCommand.Options[1] = &wordsOption
+
+	return &guildCommand
+}
+
+// registerCommand registers a command to every guild in the config, returns the last error
+func registerCommand(s botSession, command *discordgo.ApplicationCommand) error {
+	var lastErr error
+
+	for _, guildID := range commandGuildIDs() {
+		if _, err := s.ApplicationCommandCreate(s.userID(), guildID, guildCommand(command, guildID)); err != nil {
+			logger.Error("Cannot create command", "command", command.Name, "guild", guildID, "error", err)
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
+
+// guildHasModel checks if a model can be used in a guild
+func guildHasModel(guildID string, model *botModel) bool {
+	settings := guildSettings(guildID)
+	if len(settings.ModelsToUse) == 0 {
+		return true
+	}
+
+	for _, modelToUse := range settings.ModelsToUse {
+		if modelToUse == model.Info.ID || modelToUse == model.File.Path || modelToUse == path.Base(model.File.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// channelAllowed checks if commands can be used in the channel of an interaction
+func channelAllowed(i *discordgo.InteractionCreate) bool {
+	settings := guildSettings(i.GuildID)
+	if len(settings.AllowedChannelIDs) == 0 {
+		return true
+	}
+
+	return containsID(settings.AllowedChannelIDs, i.ChannelID)
+}
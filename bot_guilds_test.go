@@ -0,0 +1,68 @@
+package main
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestGuildSettings(t *testing.T) {
+	oldConfig := LoadedConfig
+	defer func() { LoadedConfig = oldConfig }()
+
+	LoadedConfig = &MainBotConfig{
+		GuildID:      "main",
+		MaxWords:     200,
+		DefaultWords: 50,
+		DefaultModel: "friends",
+		Guilds: map[string]GuildConfig{
+			"small": {MaxWords: 20, ModelsToUse: []string{"friends", "other.gob"}, DefaultModel: "other"},
+			"main":  {DefaultWords: 100},
+		},
+	}
+
+	if settings := guildSettings("small"); settings.MaxWords != 20 || settings.DefaultWords != 20 {
+		t.Errorf("expected maximum 20 & default 20 words in small guild, got %d & %d", settings.MaxWords, settings.DefaultWords)
+	}
+	if settings := guildSettings("main"); settings.MaxWords != 200 || settings.DefaultWords != 100 {
+		t.Errorf("expected maximum 200 & default 100 words in main guild, got %d & %d", settings.MaxWords, settings.DefaultWords)
+	}
+	if settings := guildSettings(""); settings.MaxWords != 200 || settings.DefaultWords != 50 {
+		t.Errorf("expected the global settings in DMs, got %d & %d", settings.MaxWords, settings.DefaultWords)
+	}
+
+	if defaultModel := guildSettings("small").DefaultModel; defaultModel != "other" {
+		t.Errorf("expected default model other in small guild, got %s", defaultModel)
+	}
+	if defaultModel := guildSettings("main").DefaultModel; defaultModel != "friends" {
+		t.Errorf("expected the global default model in main guild, got %s", defaultModel)
+	}
+
+	guildIDs := commandGuildIDs()
+	sort.Strings(guildIDs)
+	if len(guildIDs) != 2 || guildIDs[0] != "main" || guildIDs[1] != "small" {
+		t.Errorf("expected commands to be registered to main & small, got %v", guildIDs)
+	}
+
+	// every guild gets its own maximum without changing the original command
+	command := guildCommand(botCommands[0], "small")
+	if command.Options[1].MaxValue != 20 {
+		t.Errorf("expected maximum of 20 words in the command, got %v", command.Options[1].MaxValue)
+	}
+	if botCommands[0].Options[1].MaxValue == 20 {
+		t.Error("original command was changed")
+	}
+
+	friends := &botModel{Info: &ModelInfo{ID: "friends"}, File: modelFile{Path: "/models/friends.gob"}}
+	other := &botModel{Info: &ModelInfo{ID: "other"}, File: modelFile{Path: "/models/other.gob"}}
+	secret := &botModel{Info: &ModelInfo{ID: "secret"}, File: modelFile{Path: "/models/secret.gob"}}
+
+	if guildHasModel("small", friends) == false || guildHasModel("small", other) == false {
+		t.Error("models listed by ID or filename were not found in the guild")
+	}
+	if guildHasModel("small", secret) == true {
+		t.Error("model that wasn't listed was found in the guild")
+	}
+	if guildHasModel("main", secret) == false {
+		t.Error("guild without models listed should have every model")
+	}
+}
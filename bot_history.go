@@ -0,0 +1,312 @@
+package main
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"github.com/mb-14/gomarkov"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// prefix of the custom IDs of the buttons under generated text
+const generationButtonID = "generation"
+
+const (
+	// how long the buttons of a generated text keep working
+	generationHistoryAge = 24 * time.Hour
+	// most generated texts kept in the history
+	generationHistorySize = 1000
+	// most versions of a text kept in the history
+	generationVersions = 10
+)
+
+// generation a text generated with generate-text & the options it was generated with
+type generation struct {
+	// ID of the model used
+	ModelID string
+	// Amount of words asked for
+	Words int
+	// ID of the user who generated the text
+	UserID string
+	// Versions of the text, the one shown last
+	Texts []string
+	// IDs of the messages the text was sent in, the buttons are on the first one
+	MessageIDs []string
+	// Time the text was first generated
+	Created time.Time
+
+	// whether a button of the text is being handled
+	busy bool
+}
+
+var (
+	// lock for generationHistory & the generations in it
+	historyMutex sync.Mutex
+	// texts generated with generate-text by the ID of their first message
+	generationHistory = make(map[string]*generation)
+)
+
+// generationButtons returns the buttons shown under generated text
+func generationButtons() []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{Label: "Regenerate", Style: discordgo.PrimaryButton, CustomID: generationButtonID + ":regenerate"},
+				discordgo.Button{Label: "Longer", Style: discordgo.SecondaryButton, CustomID: generationButtonID + ":longer"},
+				discordgo.Button{Label: "Delete", Style: discordgo.DangerButton, CustomID: generationButtonID + ":delete"},
+			},
+		},
+	}
+}
+
+// generationHeader returns the line shown above generated text
+func generationHeader(words int, modelName string) string {
+	return "Generating text with " + strconv.Itoa(words) + " words using model " + modelName
+}
+
+// sendGeneratedText edits the interaction response to the text with the buttons under it, the parts that don't fit
+// are sent as followup messages. Returns the IDs of the messages, nil if the response couldn't be edited
+func sendGeneratedText(s botSession, i *discordgo.InteractionCreate, content string) []string {
+	messagesToSend := splitText(content)
+	messageIDs := make([]string, 0, len(messagesToSend))
+
+	for index := range messagesToSend {
+		// edit the first message
+		if index == 0 {
+			message, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+				Content:    messagesToSend[index],
+				Components: generationButtons(),
+			})
+			if err != nil {
+				logger.Error("Failed to edit message", "error", err)
+
+				if _, err := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+					Content: "Something went wrong",
+				}); err != nil {
+					logger.Error("Failed to send followup message", "error", err)
+				}
+				return nil
+			}
+			messageIDs = append(messageIDs, message.ID)
+			continue
+		}
+
+		if botContext.Err() != nil {
+			logger.Warn("Shutting down, not sending the rest of the text", "interaction", i.ID)
+			break
+		}
+
+		// send the rest as followup messages
+		message, err := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+			Content: messagesToSend[index],
+		})
+		if err != nil {
+			logger.Error("Failed to create followup message", "error", err)
+			break
+		}
+		messageIDs = append(messageIDs, message.ID)
+	}
+	return messageIDs
+}
+
+// addGeneration adds a generated text to the history, dropping the oldest texts if there are too many
+func addGeneration(entry *generation) {
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+
+	for messageID, old := range generationHistory {
+		if time.Since(old.Created) > generationHistoryAge {
+			delete(generationHistory, messageID)
+		}
+	}
+
+	for len(generationHistory) >= generationHistorySize {
+		oldestID := ""
+		for messageID, old := range generationHistory {
+			if oldestID == "" || old.Created.Before(generationHistory[oldestID].Created) {
+				oldestID = messageID
+			}
+		}
+		delete(generationHistory, oldestID)
+	}
+
+	generationHistory[entry.MessageIDs[0]] = entry
+}
+
+// startGeneration returns the generated text of a message & marks it busy, or an error message for the user.
+// finishGeneration has to be called when done with the text
+func startGeneration(messageID string, userID string, admin bool) (*generation, string) {
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+
+	entry, ok := generationHistory[messageID]
+	if ok == false || time.Since(entry.Created) > generationHistoryAge {
+		return nil, "This text is too old to change, generate a new one"
+	}
+	if entry.UserID != userID && admin == false {
+		return nil, "Only the user who generated this text can change it"
+	}
+	if entry.busy {
+		return nil, "This text is already being changed, wait a moment"
+	}
+
+	entry.busy = true
+	return entry, ""
+}
+
+// finishGeneration marks a generated text as not busy
+func finishGeneration(entry *generation) {
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+
+	entry.busy = false
+}
+
+// generationButtonHandler handler for the buttons under generated text
+func generationButtonHandler(s botSession, i *discordgo.InteractionCreate) {
+	user := interactionUser(i)
+	if user == nil || i.Message == nil {
+		return
+	}
+
+	_, action, _ := strings.Cut(i.MessageComponentData().CustomID, ":")
+
+	// admins can remove texts, only the user who generated a text can change it
+	entry, refusal := startGeneration(i.Message.ID, user.ID, action == "delete" && isBotAdmin(i))
+	if entry == nil {
+		respondEphemeral(s, i, refusal)
+		return
+	}
+	defer finishGeneration(entry)
+
+	switch action {
+	case "regenerate", "longer":
+		changeGeneration(s, i, entry, action == "longer")
+	case "delete":
+		deleteGeneration(s, i, entry)
+	}
+}
+
+// changeGeneration generates the text again with the same model & amount of words, or continues it if longer is set
+func changeGeneration(s botSession, i *discordgo.InteractionCreate, entry *generation, longer bool) {
+	// the model can be disabled or removed after the text was generated
+	model := getModel(entry.ModelID)
+	if model == nil || model.Info.ID != entry.ModelID || modelUsable(model, i) == false {
+		respondEphemeral(s, i, "Model "+entry.ModelID+" can't be used anymore")
+		return
+	}
+
+	wordModel, err := model.load()
+	if err != nil {
+		logger.Error("Failed to load model", "model", model.Info.ID, "error", err)
+		respondEphemeral(s, i, "Failed to load model "+model.Info.Name)
+		return
+	}
+
+	maxWords := guildSettings(i.GuildID).MaxWords
+	currentText := entry.Texts[len(entry.Texts)-1]
+
+	// the end of the chain isn't continued from
+	currentText = strings.TrimSuffix(currentText, " "+gomarkov.EndToken)
+	currentWords := strings.Fields(currentText)
+
+	amountOfWords := entry.Words
+	if longer && amountOfWords > maxWords-len(currentWords) {
+		amountOfWords = maxWords - len(currentWords)
+	} else if amountOfWords > maxWords {
+		amountOfWords = maxWords
+	}
+
+	if longer && (amountOfWords < 1 || len(currentWords) < 1) {
+		respondEphemeral(s, i, "This text can't be made any longer")
+		return
+	}
+
+	// the text stays visible while the new one is generated
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	}); err != nil {
+		logger.Error("Failed to send interaction response", "error", err)
+		return
+	}
+
+	var newText string
+	if longer {
+		// continue from the last word, which the generated words start with
+		lastWord := currentWords[len(currentWords)-1]
+		generatedWords := amountOfWords + 1
+		moreText, err := generateText(wordModel, &generatedWords, []string{lastWord})
+		// the bot started shutting down while generating, the old text is left as it is
+		if err != nil {
+			return
+		}
+
+		moreWords := strings.Fields(moreText)
+		if len(moreWords) > 0 && moreWords[0] == lastWord {
+			moreWords = moreWords[1:]
+		}
+		if len(moreWords) > amountOfWords {
+			moreWords = moreWords[:amountOfWords]
+		}
+		newText = strings.Join(append(currentWords, moreWords...), " ")
+	} else {
+		logger.Info("Generating text", "words", amountOfWords, "model", wordModel.ID)
+		newText, err = generateText(wordModel, &amountOfWords, nil)
+		if err != nil {
+			return
+		}
+	}
+	recordInteractionUsage(i, wordModel.ID)
+
+	// the parts of the old text that didn't fit in the first message are replaced too
+	deleteFollowups(s, i, entry.MessageIDs[1:])
+
+	header := generationHeader(len(strings.Fields(newText)), wordModel.Name)
+	if longer == false {
+		header = generationHeader(amountOfWords, wordModel.Name)
+	}
+
+	messageIDs := sendGeneratedText(s, i, header+"\n\n"+newText)
+	if len(messageIDs) == 0 {
+		return
+	}
+
+	historyMutex.Lock()
+	entry.Texts = append(entry.Texts, newText)
+	if len(entry.Texts) > generationVersions {
+		entry.Texts = entry.Texts[len(entry.Texts)-generationVersions:]
+	}
+	// the first message is the one the buttons are on
+	entry.MessageIDs = append([]string{i.Message.ID}, messageIDs[1:]...)
+	historyMutex.Unlock()
+}
+
+// deleteGeneration deletes the messages of a generated text & forgets it
+func deleteGeneration(s botSession, i *discordgo.InteractionCreate, entry *generation) {
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	}); err != nil {
+		logger.Error("Failed to send interaction response", "error", err)
+		return
+	}
+
+	// the messages were sent with the interaction webhook, the bot might not be able to see the channel
+	deleteFollowups(s, i, entry.MessageIDs[1:])
+	if err := s.InteractionResponseDelete(i.Interaction); err != nil {
+		logger.Warn("Failed to delete message", "message", i.Message.ID, "error", err)
+	}
+
+	historyMutex.Lock()
+	delete(generationHistory, i.Message.ID)
+	historyMutex.Unlock()
+}
+
+// deleteFollowups deletes followup messages of generated text with the webhook of an interaction
+func deleteFollowups(s botSession, i *discordgo.InteractionCreate, messageIDs []string) {
+	for _, messageID := range messageIDs {
+		if err := s.FollowupMessageDelete(i.Interaction, messageID); err != nil {
+			logger.Warn("Failed to delete followup message", "message", messageID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"strings"
+	"testing"
+)
+
+// setupHistoryTest empties the history of generated texts
+func setupHistoryTest(t *testing.T) {
+	resetHistory := func() {
+		historyMutex.Lock()
+		generationHistory = make(map[string]*generation)
+		historyMutex.Unlock()
+	}
+
+	resetHistory()
+	t.Cleanup(resetHistory)
+}
+
+// buttonInteraction makes an interaction of a button under generated text pressed by a user
+func buttonInteraction(action string, messageID string, userID string) *discordgo.InteractionCreate {
+	return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		Type:      discordgo.InteractionMessageComponent,
+		ChannelID: "10",
+		GuildID:   "20",
+		Member:    &discordgo.Member{User: &discordgo.User{ID: userID, Username: "tester"}},
+		Message:   &discordgo.Message{ID: messageID},
+		Data:      discordgo.MessageComponentInteractionData{CustomID: generationButtonID + ":" + action},
+	}}
+}
+
+func TestGenerationButtons(t *testing.T) {
+	setupBotTest(t, &MainBotConfig{MaxWords: 20, DefaultWords: 5}, "hello")
+	setupHistoryTest(t)
+
+	s := newFakeSession()
+	interactionHandler(s, commandInteraction("generate-text", stringOption("model", "test")))
+
+	if len(s.edits) != 1 || len(s.edits[0].Components) != 1 {
+		t.Fatalf("expected the text to be sent with a row of buttons, got %+v", s.edits)
+	}
+
+	// only the user who generated the text can change it
+	s = newFakeSession()
+	interactionHandler(s, buttonInteraction("regenerate", "response", "31"))
+
+	if len(s.responses) != 1 || s.responses[0].Data.Content != "Only the user who generated this text can change it" {
+		t.Errorf("expected another user to be refused, got %+v", s.responses)
+	}
+
+	s = newFakeSession()
+	interactionHandler(s, buttonInteraction("regenerate", "response", "30"))
+
+	if len(s.responses) != 1 || s.responses[0].Type != discordgo.InteractionResponseDeferredMessageUpdate {
+		t.Fatalf("expected the message to be updated, got %+v", s.responses)
+	}
+	if len(s.edits) != 1 || strings.HasPrefix(s.edits[0].Content, "Generating text with 5 words using model Test\n\nhello") == false {
+		t.Errorf("expected the text to be generated again with the same options, got %+v", s.edits)
+	}
+
+	// the text continues from its last word
+	s = newFakeSession()
+	interactionHandler(s, buttonInteraction("longer", "response", "30"))
+
+	if len(s.edits) != 1 || strings.HasPrefix(s.edits[0].Content, "Generating text with 2 words using model Test\n\nhello") == false {
+		t.Errorf("expected the text to be continued, got %+v", s.edits)
+	}
+
+	historyMutex.Lock()
+	versions := len(generationHistory["response"].Texts)
+	historyMutex.Unlock()
+	if versions != 3 {
+		t.Errorf("expected 3 versions of the text in the history, got %d", versions)
+	}
+
+	// texts can't be made longer than the maximum
+	LoadedConfig.MaxWords = 1
+	s = newFakeSession()
+	interactionHandler(s, buttonInteraction("longer", "response", "30"))
+
+	if len(s.responses) != 1 || s.responses[0].Data.Content != "This text can't be made any longer" {
+		t.Errorf("expected the text to be too long to continue, got %+v", s.responses)
+	}
+
+	s = newFakeSession()
+	interactionHandler(s, buttonInteraction("delete", "response", "30"))
+
+	if s.deletedResponses != 1 || len(s.deletedFollowups) != 0 {
+		t.Errorf("expected the response to be deleted, got %d responses & followups %v", s.deletedResponses, s.deletedFollowups)
+	}
+
+	// deleted texts are forgotten
+	s = newFakeSession()
+	interactionHandler(s, buttonInteraction("regenerate", "response", "30"))
+
+	if len(s.responses) != 1 || s.responses[0].Data.Content != "This text is too old to change, generate a new one" {
+		t.Errorf("expected the deleted text to be unknown, got %+v", s.responses)
+	}
+}
+
+func TestGenerationButtonsReplaceFollowups(t *testing.T) {
+	setupBotTest(t, &MainBotConfig{MaxWords: 20}, strings.Repeat("a", 4500))
+	setupHistoryTest(t)
+
+	s := newFakeSession()
+	interactionHandler(s, commandInteraction("generate-text", stringOption("model", "test")))
+
+	s = newFakeSession()
+	interactionHandler(s, buttonInteraction("regenerate", "response", "30"))
+
+	if len(s.deletedFollowups) != 2 || s.deletedFollowups[0] != "followup1" || s.deletedFollowups[1] != "followup2" ||
+		s.deletedResponses != 0 {
+		t.Errorf("expected only the old followups to be deleted, got %v", s.deletedFollowups)
+	}
+	if len(s.edits) != 1 || len(s.followups) != 2 {
+		t.Errorf("expected the new text to be sent as 1 edit & 2 followups, got %d & %d", len(s.edits), len(s.followups))
+	}
+
+	historyMutex.Lock()
+	messageIDs := generationHistory["response"].MessageIDs
+	historyMutex.Unlock()
+	if len(messageIDs) != 3 || messageIDs[0] != "response" {
+		t.Errorf("expected the history to have the new messages, got %v", messageIDs)
+	}
+
+	// the response & its followups are deleted with the interaction webhook
+	s = newFakeSession()
+	interactionHandler(s, buttonInteraction("delete", "response", "30"))
+
+	if s.deletedResponses != 1 || len(s.deletedFollowups) != 2 || s.deletedFollowups[0] != "followup1" {
+		t.Errorf("expected the response & 2 followups to be deleted, got %d responses & followups %v",
+			s.deletedResponses, s.deletedFollowups)
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// LiveModelConfig settings for a model that learns from new messages while the bot runs
+type LiveModelConfig struct {
+	// Record new messages to the model, needs the message content intent
+	Enabled bool
+	// IDs of the channels whose messages are recorded
+	ChannelIDs []string
+	// ID of the model
+	ID string
+	// Name of the model
+	Name string
+	// Description of the model
+	Description string
+	// File of the model, <ID>.gob in the model directory if empty
+	File string
+	// How often in seconds to save the model when new messages are recorded
+	SaveInterval int
+}
+
+var (
+	// the model that learns from new messages, nil if it's not enabled
+	liveModel *WordModel
+	// lock for liveModelChanged
+	liveModelMutex sync.Mutex
+	// messages were recorded since the live model was last saved
+	liveModelChanged bool
+)
+
+// liveModelPath returns the path of the live model file
+func liveModelPath() string {
+	if LoadedConfig.LiveModel.File != "" {
+		return LoadedConfig.LiveModel.File
+	}
+	return path.Join(LoadedConfig.ModelDirectory, LoadedConfig.LiveModel.ID+".gob")
+}
+
+// loadLiveModel loads the live model from its file or creates a new one if the file doesn't exist yet
+func loadLiveModel() (*WordModel, error) {
+	config := LoadedConfig.LiveModel
+	if config.ID == "" {
+		return nil, fmt.Errorf("the live model has no ID")
+	}
+
+	modelPath := liveModelPath()
+
+	wordModel, err := loadModelFile(modelPath)
+	if err == nil {
+		logger.Info("Loaded live model", "model", wordModel.ID, "messages", len(wordModel.Messages))
+
+		// users can opt out while the bot isn't running
+		if consentRegistry != nil {
+			optedOut := consentRegistry.OptedOutContributors(wordModel.Contributors)
+			if removed := wordModel.RemoveAuthors(optedOut); removed > 0 {
+				logger.Info("Removed messages of opted out users from live model", "model", wordModel.ID, "messages", removed)
+				liveModelMutex.Lock()
+				liveModelChanged = true
+				liveModelMutex.Unlock()
+			}
+		}
+		return wordModel, nil
+	} else if os.IsNotExist(err) == false {
+		return nil, fmt.Errorf("failed to load live model from %s: %v", modelPath, err)
+	}
+
+	channels := make([]int, 0, len(config.ChannelIDs))
+	for _, channelID := range config.ChannelIDs {
+		id, err := strconv.Atoi(channelID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid live model channel ID %s: %v", channelID, err)
+		}
+		channels = append(channels, id)
+	}
+
+	name := config.Name
+	if name == "" {
+		name = config.ID
+	}
+
+	logger.Info("Created new live model", "model", config.ID)
+
+	return &WordModel{
+		ID:          config.ID,
+		Name:        name,
+		Description: config.Description,
+		Words:       make([]string, 0),
+		Channels:    channels,
+		Messages:    make([]ModelMessage, 0),
+		filePath:    modelPath,
+	}, nil
+}
+
+// liveBotModel returns the live model as a model usable by the bot, nil if it's not enabled
+func liveBotModel() *botModel {
+	if liveModel == nil {
+		return nil
+	}
+
+	liveModel.mutex.Lock()
+	contributors := append([]string(nil), liveModel.Contributors...)
+	liveModel.mutex.Unlock()
+
+	return &botModel{
+		Info: &ModelInfo{ID: liveModel.ID, Name: liveModel.Name, Description: liveModel.Description, Contributors: contributors},
+		File: modelFile{Path: liveModelPath()},
+		live: liveModel,
+	}
+}
+
+// removeLiveModelUser removes the messages of a user who opted out from the live model & saves it
+func removeLiveModelUser(userID string) error {
+	removed := liveModel.RemoveAuthors([]string{userID})
+	if removed == 0 {
+		return nil
+	}
+
+	logger.Info("Removed messages of opted out user from live model", "model", liveModel.ID, "user_id", userID, "messages", removed)
+
+	liveModelMutex.Lock()
+	liveModelChanged = true
+	liveModelMutex.Unlock()
+
+	// the info of the live model has the user as a contributor
+	modelsMutex.Lock()
+	for index, model := range botModels {
+		if model.live != nil {
+			botModels[index] = liveBotModel()
+		}
+	}
+	modelsMutex.Unlock()
+
+	return saveLiveModel()
+}
+
+// modelMessageFromDiscord turns a Discord message into a ModelMessage, returns false if it has no words to learn
+func modelMessageFromDiscord(message *discordgo.Message) (ModelMessage, bool) {
+	words := SanitizeMessage(message.Content)
+	if len(words) < 1 {
+		return ModelMessage{}, false
+	}
+
+	messageID, err := strconv.Atoi(message.ID)
+	if err != nil {
+		return ModelMessage{}, false
+	}
+
+	channelID, err := strconv.Atoi(message.ChannelID)
+	if err != nil {
+		return ModelMessage{}, false
+	}
+
+	authorID := ""
+	if message.Author != nil {
+		authorID = message.Author.ID
+	}
+
+	return ModelMessage{
+		ID:        messageID,
+		ChannelID: channelID,
+		AuthorID:  authorID,
+		Timestamp: message.Timestamp,
+		Words:     words,
+	}, true
+}
+
+// liveMessageHandler records new messages from the live model channels
+func liveMessageHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
+	if liveModel == nil || m.Author == nil || m.Author.Bot {
+		return
+	}
+
+	if containsID(LoadedConfig.LiveModel.ChannelIDs, m.ChannelID) == false {
+		return
+	}
+
+	// users who have opted out are never recorded
+	if consentRegistry != nil && consentRegistry.IsOptedOut(m.Author.ID) {
+		return
+	}
+
+	message, ok := modelMessageFromDiscord(m.Message)
+	if ok == false {
+		return
+	}
+
+	// messages recorded after shutting down started wouldn't be saved
+	if startTask() == false {
+		return
+	}
+	defer finishTask()
+
+	liveModel.AddMessage(message)
+
+	liveModelMutex.Lock()
+	liveModelChanged = true
+	liveModelMutex.Unlock()
+}
+
+// saveLiveModel saves the live model if new messages were recorded
+func saveLiveModel() error {
+	liveModelMutex.Lock()
+	changed := liveModelChanged
+	liveModelChanged = false
+	liveModelMutex.Unlock()
+
+	if changed == false {
+		return nil
+	}
+
+	if err := SaveModel(liveModel, liveModelPath()); err != nil {
+		logger.Error("Failed to save live model", "model", liveModel.ID, "error", err)
+
+		// try again later
+		liveModelMutex.Lock()
+		liveModelChanged = true
+		liveModelMutex.Unlock()
+
+		return fmt.Errorf("failed to save live model %s: %v", liveModel.ID, err)
+	}
+	return nil
+}
+
+// runLiveModel saves the live model periodically until stop is closed
+func runLiveModel(stop <-chan struct{}) {
+	interval := LoadedConfig.LiveModel.SaveInterval
+	if interval <= 0 {
+		interval = 300
+	}
+
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			_ = saveLiveModel()
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestLiveModel(t *testing.T) {
+	timestamp := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	messages := []*discordgo.Message{
+		{ID: "3", ChannelID: "10", Content: "Hello there", Timestamp: timestamp, Author: &discordgo.User{ID: "456"}},
+		{ID: "4", ChannelID: "10", Content: "https://example.com", Timestamp: timestamp, Author: &discordgo.User{ID: "456"}},
+		{ID: "5", ChannelID: "10", Content: "general kenobi", Timestamp: timestamp, Author: &discordgo.User{ID: "123"}},
+	}
+
+	wordModel := &WordModel{ID: "live", Name: "Live", Channels: []int{10}}
+
+	for _, message := range messages {
+		if modelMessage, ok := modelMessageFromDiscord(message); ok {
+			wordModel.AddMessage(modelMessage)
+		}
+	}
+
+	// the message with only a URL has no words to learn
+	if len(wordModel.Messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(wordModel.Messages))
+	}
+
+	if message := wordModel.Messages[1]; message.ID != 5 || message.ChannelID != 10 || message.AuthorID != "123" ||
+		message.Timestamp.Equal(timestamp) == false {
+		t.Errorf("message was not converted correctly: %+v", message)
+	}
+
+	if len(wordModel.Words) != 4 || wordModel.Words[0] != "hello" || wordModel.Words[3] != "kenobi" {
+		t.Errorf("expected the words of both messages, got %v", wordModel.Words)
+	}
+
+	if len(wordModel.Contributors) != 2 || wordModel.Contributors[0] != "123" || wordModel.Contributors[1] != "456" {
+		t.Errorf("expected sorted contributors [123 456], got %v", wordModel.Contributors)
+	}
+
+	// the live model is used without loading it from a file
+	oldLiveModel := liveModel
+	oldConfig := LoadedConfig
+	defer func() {
+		liveModel = oldLiveModel
+		LoadedConfig = oldConfig
+	}()
+
+	liveModel = wordModel
+	LoadedConfig = &MainBotConfig{ModelDirectory: "/models", LiveModel: LiveModelConfig{ID: "live"}}
+
+	model := liveBotModel()
+	if model.Info.ID != "live" || model.File.Path != "/models/live.gob" {
+		t.Errorf("live model has the wrong info %+v or file %s", model.Info, model.File.Path)
+	}
+
+	if loadedModel, err := model.load(); err != nil || loadedModel != wordModel {
+		t.Errorf("loading the live model didn't return the model in memory: %v", err)
+	}
+}
+
+func TestLiveModelOptOut(t *testing.T) {
+	testDir, err := os.MkdirTemp(os.TempDir(), "hurabotTestLiveModelOptOut")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setupBotTest(t, &MainBotConfig{ModelDirectory: testDir, LiveModel: LiveModelConfig{ID: "live"}}, "hello")
+
+	registry, err := LoadConsentRegistry(path.Join(testDir, "consent.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldRegistry := consentRegistry
+	oldLiveModel := liveModel
+	consentRegistry = registry
+	liveModel = &WordModel{ID: "live", Name: "Live", Channels: []int{10}}
+	defer func() {
+		consentRegistry = oldRegistry
+		liveModel = oldLiveModel
+		liveModelChanged = false
+		if err := os.RemoveAll(testDir); err != nil {
+			t.Logf("failed to remove the test directory %s: %v", testDir, err)
+		}
+	}()
+
+	liveModel.AddMessage(ModelMessage{ID: 1, ChannelID: 10, AuthorID: "30", Words: []string{"secret", "words"}})
+	liveModel.AddMessage(ModelMessage{ID: 2, ChannelID: 10, AuthorID: "31", Words: []string{"hello"}})
+
+	modelsMutex.Lock()
+	botModels = append(botModels, liveBotModel())
+	modelsMutex.Unlock()
+
+	if contributors := getModel("live").Info.Contributors; len(contributors) != 2 {
+		t.Fatalf("expected the live model info to have 2 contributors, got %v", contributors)
+	}
+
+	s := newFakeSession()
+	interactionHandler(s, commandInteraction("hurabot", &discordgo.ApplicationCommandInteractionDataOption{
+		Name: "optout",
+		Type: discordgo.ApplicationCommandOptionSubCommand,
+	}))
+
+	if len(liveModel.Messages) != 1 || len(liveModel.Words) != 1 || liveModel.Words[0] != "hello" {
+		t.Errorf("expected only the message of user 31 to be left, got %v", liveModel.Messages)
+	}
+
+	// the live model can still be used without the messages of the user
+	if model := getModel("live"); hasOptedOutContributors(model) {
+		t.Errorf("expected the live model to have no opted out contributors, got %v", model.Info.Contributors)
+	}
+
+	savedModel, err := loadModelFile(path.Join(testDir, "live.gob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(savedModel.Messages) != 1 {
+		t.Errorf("expected the live model to be saved without the messages of the user, got %v", savedModel.Messages)
+	}
+
+	// messages saved before opting out are removed when the live model is loaded
+	liveModel.AddMessage(ModelMessage{ID: 3, ChannelID: 10, AuthorID: "30", Words: []string{"secret"}})
+	if err := SaveModel(liveModel, path.Join(testDir, "live.gob")); err != nil {
+		t.Fatal(err)
+	}
+
+	loadedModel, err := loadLiveModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loadedModel.Messages) != 1 || len(loadedModel.Contributors) != 1 {
+		t.Errorf("expected the messages of the opted out user to be removed when loading, got %v", loadedModel.Messages)
+	}
+}
@@ -0,0 +1,448 @@
+package main
+
+import (
+	"errors"
+	"github.com/bwmarrin/discordgo"
+	"os"
+	"os/signal"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// modelFile a model file & the state it was in when it was loaded
+type modelFile struct {
+	// Path of the model file
+	Path string
+	// Modification time of the file when it was loaded
+	ModTime time.Time
+	// Size of the file when it was loaded
+	Size int64
+}
+
+// botModel a model usable by the bot, only its info is kept in memory & the model is loaded when it's used
+type botModel struct {
+	// Info of the model
+	Info *ModelInfo
+	// File the info was loaded from
+	File modelFile
+
+	// the live model, kept in memory instead of the cache
+	live *WordModel
+}
+
+var (
+	// lock for botModels
+	modelsMutex sync.RWMutex
+	// models usable by the bot
+	botModels = make([]*botModel, 0)
+	// state of every model file found when the models were loaded, including the skipped ones, by their paths
+	modelFileStates = make(map[string]modelFile)
+	// cache for the models in use
+	wordModelCache = newModelCache(0)
+)
+
+// load returns the model from the cache, loading it from its file if needed
+func (model *botModel) load() (*WordModel, error) {
+	if model.live != nil {
+		return model.live, nil
+	}
+
+	return wordModelCache.get(model.File.Path, func() (*WordModel, error) {
+		wordModel, err := loadModelFile(model.File.Path)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("Loaded model", "model", wordModel.ID, "name", wordModel.Name, "words", len(wordModel.Words))
+		return wordModel, nil
+	})
+}
+
+// modelFilePaths returns the paths of the model files to load, either ModelsToUse or all models in the model directory.
+// The live model is not included
+func modelFilePaths() ([]string, error) {
+	modelPaths, err := configModelFilePaths()
+	if err != nil || liveModel == nil {
+		return modelPaths, err
+	}
+
+	filePaths := make([]string, 0, len(modelPaths))
+	for _, modelPath := range modelPaths {
+		if path.Clean(modelPath) != path.Clean(liveModelPath()) {
+			filePaths = append(filePaths, modelPath)
+		}
+	}
+	return filePaths, nil
+}
+
+// configModelFilePaths returns the paths of the models in ModelsToUse or all models in the model directory
+func configModelFilePaths() ([]string, error) {
+	modelPaths := make([]string, 0)
+
+	// check if models are set in config
+	if len(LoadedConfig.ModelsToUse) > 0 {
+		for _, model := range LoadedConfig.ModelsToUse {
+			modelPath := path.Clean(model)
+
+			if _, err := os.Stat(modelPath); err != nil {
+				// try to find in config models dir
+				modelPath = path.Join(LoadedConfig.ModelDirectory, model)
+			}
+
+			if _, err := os.Stat(modelPath); err != nil {
+				// try to find by model ID
+				if idPath, err := FindModelByID(LoadedConfig.ModelDirectory, model); err == nil {
+					modelPath = idPath
+				}
+			}
+
+			modelPaths = append(modelPaths, modelPath)
+		}
+		return modelPaths, nil
+	}
+
+	// load whole directory
+	modelDirectoryContents, err := os.ReadDir(LoadedConfig.ModelDirectory)
+
+	if err != nil {
+		return nil, errors.New("failed to read model folder " + LoadedConfig.ModelDirectory + ": " + err.Error())
+	}
+
+	for _, file := range modelDirectoryContents {
+		// skip backups & temporary files
+		if file.IsDir() || strings.HasSuffix(file.Name(), ".gob") == false {
+			continue
+		}
+		modelPaths = append(modelPaths, path.Join(LoadedConfig.ModelDirectory, file.Name()))
+	}
+	return modelPaths, nil
+}
+
+// statModelFile returns the current state of a model file
+func statModelFile(modelPath string) (modelFile, error) {
+	fileInfo, err := os.Stat(modelPath)
+	if err != nil {
+		return modelFile{}, err
+	}
+	return modelFile{Path: modelPath, ModTime: fileInfo.ModTime(), Size: fileInfo.Size()}, nil
+}
+
+// loadModelFile opens & decodes a single model file
+func loadModelFile(modelPath string) (*WordModel, error) {
+	file, err := os.Open(modelPath)
+	if err != nil {
+		return nil, err
+	}
+
+	wordModel, err := LoadModel(file)
+
+	if err := file.Close(); err != nil {
+		logger.Warn("Failed to close model file", "file", file.Name(), "error", err)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return wordModel, nil
+}
+
+// loadModelInfoFile opens a single model file & decodes its info
+func loadModelInfoFile(modelPath string) (*ModelInfo, error) {
+	file, err := os.Open(modelPath)
+	if err != nil {
+		return nil, err
+	}
+
+	modelInfo, err := LoadModelInfo(file)
+
+	if err := file.Close(); err != nil {
+		logger.Warn("Failed to close model file", "file", file.Name(), "error", err)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return modelInfo, nil
+}
+
+// loadModels loads the info of the model files, reusing the info of models whose files haven't changed.
+// Returns true if anything was loaded or removed
+func loadModels() (bool, error) {
+	modelPaths, err := modelFilePaths()
+	if err != nil {
+		return false, err
+	}
+
+	modelsMutex.RLock()
+	loadedModels := make(map[string]*botModel, len(botModels))
+	for _, model := range fileModels() {
+		loadedModels[model.File.Path] = model
+	}
+	liveLoaded := len(loadedModels) != len(botModels)
+	modelsMutex.RUnlock()
+
+	newModels := make([]*botModel, 0, len(modelPaths)+1)
+	modelIDs := make(map[string]string, len(modelPaths)+1)
+	fileStates := make(map[string]modelFile, len(modelPaths))
+	changed := false
+
+	// the live model always keeps its ID
+	live := liveBotModel()
+	if live != nil {
+		modelIDs[live.Info.ID] = live.File.Path
+	}
+
+	for _, modelPath := range modelPaths {
+		file, err := statModelFile(modelPath)
+		fileStates[modelPath] = file
+		if err != nil {
+			logger.Error("Failed to load model file", "file", modelPath, "error", err)
+			continue
+		}
+
+		// keep using the loaded model if the file hasn't changed
+		model, ok := loadedModels[modelPath]
+		if ok == false || model.File != file {
+			modelInfo, err := loadModelInfoFile(modelPath)
+			if err != nil {
+				logger.Error("Failed to load model from file", "file", modelPath, "error", err)
+				continue
+			}
+
+			// the old version of the model can't be used anymore
+			wordModelCache.remove(modelPath)
+
+			logger.Info("Found model", "model", modelInfo.ID, "name", modelInfo.Name, "file", modelPath)
+
+			model = &botModel{Info: modelInfo, File: file}
+			changed = true
+		}
+
+		// models are chosen by their ID so they have to be unique
+		if otherPath, ok := modelIDs[model.Info.ID]; ok {
+			logger.Warn("Skipping model file, the model ID is already used", "file", modelPath, "model", model.Info.ID, "used_by", otherPath)
+			continue
+		}
+		modelIDs[model.Info.ID] = modelPath
+
+		newModels = append(newModels, model)
+	}
+
+	if len(newModels) != len(loadedModels) || (live != nil && liveLoaded == false) {
+		changed = true
+	}
+
+	if changed == false {
+		modelsMutex.Lock()
+		modelFileStates = fileStates
+		modelsMutex.Unlock()
+		return false, nil
+	}
+
+	// remove the models that aren't used anymore from the cache
+	for _, model := range newModels {
+		delete(loadedModels, model.File.Path)
+	}
+	for modelPath := range loadedModels {
+		wordModelCache.remove(modelPath)
+	}
+
+	if live != nil {
+		newModels = append(newModels, live)
+	}
+
+	modelsMutex.Lock()
+	botModels = newModels
+	modelFileStates = fileStates
+	modelsMutex.Unlock()
+
+	return true, nil
+}
+
+// modelFilesChanged checks if model files were added, changed or removed since they were loaded.
+// Files that were skipped only count as changed if they were changed themselves
+func modelFilesChanged() bool {
+	modelPaths, err := modelFilePaths()
+	if err != nil {
+		logger.Error("Failed to check model files", "error", err)
+		return false
+	}
+
+	modelsMutex.RLock()
+	defer modelsMutex.RUnlock()
+
+	foundPaths := make(map[string]bool, len(modelPaths))
+	for _, modelPath := range modelPaths {
+		foundPaths[modelPath] = true
+
+		// files that can't be found have an empty state, like when they were loaded
+		file, _ := statModelFile(modelPath)
+		if loadedFile, ok := modelFileStates[modelPath]; ok == false || file != loadedFile {
+			return true
+		}
+	}
+	return len(foundPaths) != len(modelFileStates)
+}
+
+// fileModels returns the models loaded from files, without the live model. modelsMutex has to be locked
+func fileModels() []*botModel {
+	models := make([]*botModel, 0, len(botModels))
+	for _, model := range botModels {
+		if model.live == nil {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
+// getModel returns a model by the value of a model option, nil if there is no such model.
+// The value is the model's ID, or its name if it was typed without using autocomplete
+func getModel(value string) *botModel {
+	modelsMutex.RLock()
+	defer modelsMutex.RUnlock()
+
+	for _, model := range botModels {
+		if model.Info.ID == value {
+			return model
+		}
+	}
+
+	for _, model := range botModels {
+		if strings.EqualFold(model.Info.Name, value) {
+			return model
+		}
+	}
+	return nil
+}
+
+// searchModels returns the models whose ID, name or description contains the query, at most limit models.
+// Models for which usable returns false are skipped, nil includes every model
+func searchModels(query string, limit int, usable func(model *botModel) bool) []*botModel {
+	modelsMutex.RLock()
+	defer modelsMutex.RUnlock()
+
+	query = strings.ToLower(query)
+	models := make([]*botModel, 0, limit)
+
+	for _, model := range botModels {
+		if len(models) >= limit {
+			break
+		}
+
+		if usable != nil && usable(model) == false {
+			continue
+		}
+
+		if strings.Contains(model.Info.ID, query) ||
+			strings.Contains(strings.ToLower(model.Info.Name), query) ||
+			strings.Contains(strings.ToLower(model.Info.Description), query) {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
+// modelAutocompleteHandler handler for autocompleting model options, only models for which usable returns true are suggested
+func modelAutocompleteHandler(s botSession, i *discordgo.InteractionCreate, usable func(model *botModel) bool) {
+	option := focusedOption(i.ApplicationCommandData().Options)
+	if option == nil || option.Name != "model" {
+		return
+	}
+
+	// Discord shows at most 25 choices
+	models := searchModels(option.StringValue(), 25, usable)
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, len(models))
+
+	for _, model := range models {
+		name := model.Info.Name
+		if model.Info.Description != "" {
+			name += " - " + model.Info.Description
+		}
+
+		// choice names can be at most 100 characters
+		name = truncateText(name, 100)
+
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  name,
+			Value: model.Info.ID,
+		})
+	}
+
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	}); err != nil {
+		logger.Error("Failed to send autocomplete choices", "error", err)
+	}
+}
+
+// focusedOption returns the option the user is typing, searching subcommands too
+func focusedOption(options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.ApplicationCommandInteractionDataOption {
+	for _, option := range options {
+		if option.Focused {
+			return option
+		}
+		if focused := focusedOption(option.Options); focused != nil {
+			return focused
+		}
+	}
+	return nil
+}
+
+// reloadModels loads the info of changed models, autocomplete uses the new models right away
+func reloadModels() error {
+	changed, err := loadModels()
+	if err != nil {
+		return err
+	}
+
+	if changed == false {
+		logger.Info("No model changes found")
+		return nil
+	}
+
+	modelsMutex.RLock()
+	logger.Info("Models reloaded", "models", len(botModels))
+	modelsMutex.RUnlock()
+
+	return nil
+}
+
+// watchModels reloads the models when model files change or SIGHUP is received, until stop is closed
+func watchModels(stop <-chan struct{}) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	// polling is disabled if the interval is not set
+	var poll <-chan time.Time
+	if LoadedConfig.ModelReloadInterval > 0 {
+		ticker := time.NewTicker(time.Duration(LoadedConfig.ModelReloadInterval) * time.Second)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-hangup:
+			logger.Info("Received SIGHUP, reloading models")
+		case <-poll:
+			if modelFilesChanged() == false {
+				continue
+			}
+			logger.Info("Model files changed, reloading models")
+		}
+
+		if err := reloadModels(); err != nil {
+			logger.Error("Failed to reload models", "error", err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestModelFilesChanged(t *testing.T) {
+	testDir, err := os.MkdirTemp(os.TempDir(), "hurabotTestModelFilesChanged")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setupBotTest(t, &MainBotConfig{ModelDirectory: testDir}, "hello")
+
+	wordModel := &WordModel{ID: "saved", Name: "Saved", Words: []string{"hello"}}
+	if err := SaveModel(wordModel, path.Join(testDir, "saved.gob")); err != nil {
+		t.Fatal(err)
+	}
+	// a copy with the same ID & a file that isn't a model are skipped
+	if err := SaveModel(wordModel, path.Join(testDir, "copy.gob")); err != nil {
+		t.Fatal(err)
+	}
+	brokenPath := path.Join(testDir, "broken.gob")
+	if err := os.WriteFile(brokenPath, []byte("not a model"), 0660); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := loadModels(); err != nil {
+		t.Fatal(err)
+	}
+
+	if models := fileModels(); len(models) != 1 || models[0].Info.ID != "saved" {
+		t.Fatalf("expected only the saved model to be loaded, got %+v", models)
+	}
+
+	if modelFilesChanged() {
+		t.Error("expected the skipped files not to count as changed")
+	}
+
+	if err := os.WriteFile(brokenPath, []byte("still not a model"), 0660); err != nil {
+		t.Fatal(err)
+	}
+	if modelFilesChanged() == false {
+		t.Error("expected the changed skipped file to count as changed")
+	}
+
+	if _, err := loadModels(); err != nil {
+		t.Fatal(err)
+	}
+	if modelFilesChanged() {
+		t.Error("expected no changes after reloading")
+	}
+
+	if err := os.Remove(brokenPath); err != nil {
+		t.Fatal(err)
+	}
+	if modelFilesChanged() == false {
+		t.Error("expected the removed file to count as changed")
+	}
+
+	if err := os.RemoveAll(testDir); err != nil {
+		t.Logf("failed to remove the test directory %s: %v", testDir, err)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PassiveConfig settings for replying to messages without commands
+type PassiveConfig struct {
+	// Reply to messages in ChannelIDs, needs the message content intent
+	Enabled bool
+	// IDs of the channels where the bot reads messages
+	ChannelIDs []string
+	// ID of the model to reply with
+	Model string
+	// Amount of words to generate, the default amount of the guild if 0
+	Words int
+	// Reply when the bot is mentioned
+	ReplyToMentions bool
+	// Reply when a message contains any of these words
+	Keywords []string
+	// Chance to reply to any other message, from 0 to 1
+	Probability float64
+	// Seconds to wait between replies in a channel
+	Cooldown int
+}
+
+var (
+	// lock for lastPassiveReplies
+	passiveMutex sync.Mutex
+	// time of the last passive reply by channel ID
+	lastPassiveReplies = make(map[string]time.Time)
+)
+
+// passiveTriggered checks if a message should get a reply, roll is a random number from 0 to 1
+func passiveTriggered(config PassiveConfig, content string, mentioned bool, roll float64) bool {
+	if mentioned && config.ReplyToMentions {
+		return true
+	}
+
+	content = strings.ToLower(content)
+	for _, keyword := range config.Keywords {
+		if keyword != "" && strings.Contains(content, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+
+	return roll < config.Probability
+}
+
+// passiveCooldownOver checks if the cooldown of a channel is over & starts a new one if it is
+func passiveCooldownOver(channelID string, cooldown time.Duration, now time.Time) bool {
+	passiveMutex.Lock()
+	defer passiveMutex.Unlock()
+
+	if lastReply, ok := lastPassiveReplies[channelID]; ok && now.Sub(lastReply) < cooldown {
+		return false
+	}
+
+	lastPassiveReplies[channelID] = now
+	return true
+}
+
+// passiveMessageHandler replies to messages in the passive channels with generated text
+func passiveMessageHandler(s botSession, m *discordgo.MessageCreate) {
+	config := LoadedConfig.Passive
+
+	if config.Enabled == false || m.Author == nil || m.Author.Bot || m.Author.ID == s.userID() {
+		return
+	}
+
+	if containsID(config.ChannelIDs, m.ChannelID) == false {
+		return
+	}
+
+	if startTask() == false {
+		return
+	}
+	defer finishTask()
+
+	mentioned := false
+	for _, user := range m.Mentions {
+		if user.ID == s.userID() {
+			mentioned = true
+		}
+	}
+
+	if passiveTriggered(config, m.Content, mentioned, rand.Float64()) == false {
+		return
+	}
+
+	if passiveCooldownOver(m.ChannelID, time.Duration(config.Cooldown)*time.Second, time.Now()) == false {
+		return
+	}
+
+	var roleIDs []string
+	if m.Member != nil {
+		roleIDs = m.Member.Roles
+	}
+
+	model := getModel(config.Model)
+	if model == nil || isModelDisabled(model.Info.ID) || hasOptedOutContributors(model) ||
+		canUseModelAs(model.Info.ID, m.Author.ID, m.ChannelID, m.GuildID, roleIDs) == false ||
+		guildHasModel(m.GuildID, model) == false {
+		logger.Warn("Model can't be used for replying", "model", config.Model, "channel", m.ChannelID)
+		return
+	}
+
+	wordModel, err := model.load()
+	if err != nil {
+		logger.Error("Failed to load model", "model", model.Info.ID, "error", err)
+		return
+	}
+
+	settings := guildSettings(m.GuildID)
+	amountOfWords := config.Words
+	if amountOfWords <= 0 {
+		amountOfWords = settings.DefaultWords
+	}
+	if amountOfWords > settings.MaxWords {
+		amountOfWords = settings.MaxWords
+	}
+
+	logger.Info("Replying to message", "message", m.ID, "channel", m.ChannelID, "words", amountOfWords, "model", wordModel.ID)
+
+	// start from a word of the message if the model has any of them
+	generatedText, err := generateText(wordModel, &amountOfWords, SanitizeMessage(m.Content))
+	if err != nil {
+		logger.Warn("Shutting down, not replying to message", "message", m.ID)
+		return
+	}
+
+	// the live model is empty until it has learned some messages
+	if strings.TrimSpace(generatedText) == "" {
+		logger.Warn("Model has no words to reply with", "model", wordModel.ID, "message", m.ID)
+		return
+	}
+	recordUsage(m.Author.ID, wordModel.ID, m.GuildID)
+
+	if err := sendReply(s, m.ChannelID, generatedText, m.Reference()); err != nil {
+		logger.Error("Failed to reply to message", "message", m.ID, "error", err)
+	}
+}
+
+// sendReply sends text as a reply to a message, splitting it to multiple messages if needed
+func sendReply(s botSession, channelID string, text string, reference *discordgo.MessageReference) error {
+	for index, message := range splitText(text) {
+		var err error
+
+		// only the first message is a reply
+		if index == 0 {
+			_, err = s.ChannelMessageSendReply(channelID, message, reference)
+		} else {
+			_, err = s.ChannelMessageSend(channelID, message)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
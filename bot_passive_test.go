@@ -0,0 +1,81 @@
+package main
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"testing"
+	"time"
+)
+
+func TestPassiveTriggered(t *testing.T) {
+	config := PassiveConfig{
+		ReplyToMentions: true,
+		Keywords:        []string{"Hurabot", "markov"},
+		Probability:     0.1,
+	}
+
+	tests := []struct {
+		name      string
+		content   string
+		mentioned bool
+		roll      float64
+		triggered bool
+	}{
+		{"mention", "hello", true, 0.9, true},
+		{"keyword", "is hurabot here?", false, 0.9, true},
+		{"no trigger", "hello", false, 0.9, false},
+		{"lucky roll", "hello", false, 0.05, true},
+	}
+
+	for _, test := range tests {
+		if triggered := passiveTriggered(config, test.content, test.mentioned, test.roll); triggered != test.triggered {
+			t.Errorf("%s: expected triggered to be %t, got %t", test.name, test.triggered, triggered)
+		}
+	}
+
+	config.ReplyToMentions = false
+	if passiveTriggered(config, "hello", true, 0.9) == true {
+		t.Error("mention triggered a reply with ReplyToMentions disabled")
+	}
+}
+
+func TestPassiveCooldown(t *testing.T) {
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	defer delete(lastPassiveReplies, "cooldown test")
+
+	if passiveCooldownOver("cooldown test", 30*time.Second, now) == false {
+		t.Error("first reply was on cooldown")
+	}
+	if passiveCooldownOver("cooldown test", 30*time.Second, now.Add(10*time.Second)) == true {
+		t.Error("reply during the cooldown was allowed")
+	}
+	if passiveCooldownOver("cooldown test", 30*time.Second, now.Add(30*time.Second)) == false {
+		t.Error("reply after the cooldown was not allowed")
+	}
+}
+
+func TestPassiveEmptyModel(t *testing.T) {
+	setupBotTest(t, &MainBotConfig{
+		MaxWords:     20,
+		DefaultWords: 3,
+		Passive:      PassiveConfig{Enabled: true, ChannelIDs: []string{"passive"}, Model: "test", ReplyToMentions: true},
+	}, "hello")
+	defer delete(lastPassiveReplies, "passive")
+
+	// the live model has learned no messages yet
+	modelsMutex.Lock()
+	botModels[0].live.Words = nil
+	modelsMutex.Unlock()
+
+	s := newFakeSession()
+	passiveMessageHandler(s, &discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:        "1",
+		ChannelID: "passive",
+		Content:   "hello bot",
+		Author:    &discordgo.User{ID: "30"},
+		Mentions:  []*discordgo.User{{ID: s.userID()}},
+	}})
+
+	if len(s.messages["passive"]) != 0 {
+		t.Errorf("expected no reply from an empty model, got %v", s.messages)
+	}
+}
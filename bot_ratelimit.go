@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimit how many commands can be used in a period of time
+type RateLimit struct {
+	// Amount of commands that can be used in a row, 0 disables the limit
+	Commands int
+	// Seconds it takes for all the commands to be usable again
+	Seconds int
+}
+
+// amount of buckets after which full buckets are removed
+const rateLimiterCleanupSize = 1024
+
+// tokenBucket the tokens left for a single user, channel or guild
+type tokenBucket struct {
+	// tokens left, one is used for each command
+	tokens float64
+	// time the tokens were last refilled
+	updated time.Time
+}
+
+// rateLimiter limits commands with a token bucket for each key
+type rateLimiter struct {
+	mutex sync.Mutex
+	// maximum amount of tokens in a bucket
+	capacity float64
+	// tokens added to a bucket per second
+	rate float64
+	// buckets by key
+	buckets map[string]*tokenBucket
+	// returns the current time, replaced in tests
+	now func() time.Time
+}
+
+var (
+	// rate limiter for each user
+	userRateLimiter *rateLimiter
+	// rate limiter for each channel
+	channelRateLimiter *rateLimiter
+	// rate limiter for each guild
+	guildRateLimiter *rateLimiter
+)
+
+// newRateLimiter creates a rateLimiter from a RateLimit, nil if the limit is disabled
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	if limit.Commands <= 0 {
+		return nil
+	}
+
+	// without a period the tokens are never refilled
+	seconds := limit.Seconds
+	if seconds <= 0 {
+		seconds = 1
+	}
+
+	return &rateLimiter{
+		capacity: float64(limit.Commands),
+		rate:     float64(limit.Commands) / float64(seconds),
+		buckets:  make(map[string]*tokenBucket),
+		now:      time.Now,
+	}
+}
+
+// refill returns the bucket of a key with the tokens added since it was last used, the mutex has to be locked
+func (l *rateLimiter) refill(key string, now time.Time) *tokenBucket {
+	bucket, ok := l.buckets[key]
+	if ok == false {
+		bucket = &tokenBucket{tokens: l.capacity, updated: now}
+		l.buckets[key] = bucket
+		return bucket
+	}
+
+	bucket.tokens = math.Min(l.capacity, bucket.tokens+now.Sub(bucket.updated).Seconds()*l.rate)
+	bucket.updated = now
+	return bucket
+}
+
+// wait returns how long the key has to wait until it can use a command, 0 if it can use one now
+func (l *rateLimiter) wait(key string) time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	bucket := l.refill(key, l.now())
+	if bucket.tokens >= 1 {
+		return 0
+	}
+
+	return time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+}
+
+// take uses a token from the bucket of a key
+func (l *rateLimiter) take(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	l.refill(key, now).tokens--
+
+	l.cleanup(now)
+}
+
+// cleanup removes the buckets that are full again when there are many of them, the mutex has to be locked
+func (l *rateLimiter) cleanup(now time.Time) {
+	if len(l.buckets) < rateLimiterCleanupSize {
+		return
+	}
+
+	for key, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.updated).Seconds()*l.rate >= l.capacity {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// initRateLimiters creates the rate limiters from the loaded config
+func initRateLimiters() {
+	userRateLimiter = newRateLimiter(LoadedConfig.UserRateLimit)
+	channelRateLimiter = newRateLimiter(LoadedConfig.ChannelRateLimit)
+	guildRateLimiter = newRateLimiter(LoadedConfig.GuildRateLimit)
+}
+
+// checkRateLimits checks the user, channel & guild limits of an interaction & uses a command from each if all allow it.
+// Returns how long to wait if any of the limits is reached
+func checkRateLimits(i *discordgo.InteractionCreate) (bool, time.Duration) {
+	userID := ""
+	if user := interactionUser(i); user != nil {
+		userID = user.ID
+	}
+
+	limits := []struct {
+		limiter *rateLimiter
+		key     string
+	}{
+		{userRateLimiter, userID},
+		{channelRateLimiter, i.ChannelID},
+		{guildRateLimiter, i.GuildID},
+	}
+
+	// check every limit before using any so a denied command doesn't count
+	for _, limit := range limits {
+		if limit.limiter == nil || limit.key == "" {
+			continue
+		}
+		if wait := limit.limiter.wait(limit.key); wait > 0 {
+			return false, wait
+		}
+	}
+
+	for _, limit := range limits {
+		if limit.limiter == nil || limit.key == "" {
+			continue
+		}
+		limit.limiter.take(limit.key)
+	}
+
+	return true, 0
+}
+
+// respondRateLimited tells the user to slow down
+func respondRateLimited(s botSession, i *discordgo.InteractionCreate, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	respondEphemeral(s, i, fmt.Sprintf("Slow down! Try again in %d seconds.", seconds))
+}
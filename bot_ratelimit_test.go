@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// 3 commands per 30 seconds, so one command every 10 seconds. Waits are rounded because of floating point errors
+	limiter := newRateLimiter(RateLimit{Commands: 3, Seconds: 30})
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if wait := limiter.wait("user"); wait != 0 {
+			t.Fatalf("command %d was limited for %v", i+1, wait)
+		}
+		limiter.take("user")
+	}
+
+	if wait := limiter.wait("user").Round(time.Millisecond); wait != 10*time.Second {
+		t.Errorf("expected to wait 10s after using all commands, got %v", wait)
+	}
+
+	// other keys have their own buckets
+	if wait := limiter.wait("other user"); wait != 0 {
+		t.Errorf("other user was limited for %v", wait)
+	}
+
+	now = now.Add(4 * time.Second)
+	if wait := limiter.wait("user").Round(time.Millisecond); wait != 6*time.Second {
+		t.Errorf("expected to wait 6s, got %v", wait)
+	}
+
+	now = now.Add(6 * time.Second)
+	if wait := limiter.wait("user"); wait != 0 {
+		t.Errorf("command was limited for %v after refill", wait)
+	}
+	limiter.take("user")
+
+	// a long pause only refills up to the capacity
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if wait := limiter.wait("user"); wait != 0 {
+			t.Fatalf("command %d was limited for %v after a pause", i+1, wait)
+		}
+		limiter.take("user")
+	}
+	if wait := limiter.wait("user"); wait == 0 {
+		t.Error("bucket was filled over its capacity")
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	if limiter := newRateLimiter(RateLimit{}); limiter != nil {
+		t.Error("rate limiter was created for a disabled limit")
+	}
+}
+
+func TestRateLimiterCleanup(t *testing.T) {
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	limiter := newRateLimiter(RateLimit{Commands: 1, Seconds: 10})
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < rateLimiterCleanupSize; i++ {
+		limiter.take(string(rune('a' + i)))
+	}
+
+	// the old buckets are full again so only the newest one should be kept
+	now = now.Add(10 * time.Second)
+	limiter.take("new")
+
+	if len(limiter.buckets) != 1 {
+		t.Errorf("expected 1 bucket after cleanup, got %d", len(limiter.buckets))
+	}
+}
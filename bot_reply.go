@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"strings"
+)
+
+// custom ID prefix of the model select menu, followed by the ID of the message to reply to
+const replyModelSelectID = "reply-as-model"
+
+// replyAsModelHandler handler for the Reply as model message command, replies with the default model
+// or lets the user choose the model if there is no default model
+func replyAsModelHandler(s botSession, i *discordgo.InteractionCreate) {
+	data := i.ApplicationCommandData()
+
+	message, ok := data.Resolved.Messages[data.TargetID]
+	if ok == false {
+		respondEphemeral(s, i, "Message not found")
+		return
+	}
+
+	if defaultModel := guildSettings(i.GuildID).DefaultModel; defaultModel != "" {
+		replyAsModel(s, i, defaultModel, message)
+		return
+	}
+
+	// Discord shows at most 25 options
+	models := searchModels("", 25, func(model *botModel) bool {
+		return modelUsable(model, i)
+	})
+
+	if len(models) < 1 {
+		respondEphemeral(s, i, "No models can be used here")
+		return
+	}
+
+	options := make([]discordgo.SelectMenuOption, 0, len(models))
+	for _, model := range models {
+		options = append(options, discordgo.SelectMenuOption{
+			Label:       truncateText(model.Info.Name, 100),
+			Value:       model.Info.ID,
+			Description: truncateText(model.Info.Description, 100),
+		})
+	}
+
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: "Choose the model to reply with",
+			Flags:   uint64(discordgo.MessageFlagsEphemeral),
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.SelectMenu{
+							CustomID:    replyModelSelectID + ":" + message.ID,
+							Placeholder: "Model",
+							Options:     options,
+						},
+					},
+				},
+			},
+		},
+	}); err != nil {
+		logger.Error("Failed to send model choices", "error", err)
+	}
+}
+
+// replyModelSelectHandler handler for choosing the model from the select menu of Reply as model
+func replyModelSelectHandler(s botSession, i *discordgo.InteractionCreate) {
+	data := i.MessageComponentData()
+	if len(data.Values) < 1 {
+		return
+	}
+
+	_, messageID, _ := strings.Cut(data.CustomID, ":")
+
+	message, err := s.ChannelMessage(i.ChannelID, messageID)
+	if err != nil {
+		logger.Error("Failed to get message", "message", messageID, "error", err)
+		respondEphemeral(s, i, "Message not found")
+		return
+	}
+
+	replyAsModel(s, i, data.Values[0], message)
+}
+
+// replyAsModel replies to a message with text generated from a model, starting from a word of the message if possible
+func replyAsModel(s botSession, i *discordgo.InteractionCreate, modelID string, message *discordgo.Message) {
+	model := getModel(modelID)
+	if model == nil || modelUsable(model, i) == false {
+		respondEphemeral(s, i, "Model "+modelID+" can't be used here")
+		return
+	}
+
+	// loading the model & generating can take longer than Discord waits for the response
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: uint64(discordgo.MessageFlagsEphemeral),
+		},
+	}); err != nil {
+		logger.Error("Failed to send interaction response", "error", err)
+		return
+	}
+
+	msg := replyWithModel(s, i, model, message)
+
+	if _, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: msg,
+	}); err != nil {
+		logger.Error("Failed to edit message", "error", err)
+	}
+}
+
+// replyWithModel generates the reply to a message & sends it, returns the message shown to the user
+func replyWithModel(s botSession, i *discordgo.InteractionCreate, model *botModel, message *discordgo.Message) string {
+	wordModel, err := model.load()
+	if err != nil {
+		logger.Error("Failed to load model", "model", model.Info.ID, "error", err)
+		return "Failed to load model " + model.Info.Name
+	}
+
+	amountOfWords := guildSettings(i.GuildID).DefaultWords
+
+	logger.Info("Replying to message", "message", message.ID, "words", amountOfWords, "model", wordModel.ID)
+	generatedText, err := generateText(wordModel, &amountOfWords, SanitizeMessage(message.Content))
+	if err != nil {
+		return "The bot is shutting down, try again later"
+	}
+
+	// the live model is empty until it has learned some messages
+	if strings.TrimSpace(generatedText) == "" {
+		return "Model " + model.Info.Name + " has no words to reply with yet"
+	}
+	recordInteractionUsage(i, wordModel.ID)
+
+	if err := sendReply(s, i.ChannelID, generatedText, message.Reference()); err != nil {
+		logger.Error("Failed to reply to message", "message", message.ID, "error", err)
+		return "Failed to send the reply"
+	}
+
+	return fmt.Sprintf("Replied with model %s", model.Info.Name)
+}
+
+// truncateText cuts text to at most limit characters
+func truncateText(text string, limit int) string {
+	if textRunes := []rune(text); len(textRunes) > limit {
+		return string(textRunes[:limit-1]) + "…"
+	}
+	return text
+}
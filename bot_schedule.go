@@ -0,0 +1,408 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScheduleConfig a message posted automatically on a schedule
+type ScheduleConfig struct {
+	// Unique ID of the schedule, used for managing it with commands
+	ID string
+	// ID of the channel to post to
+	ChannelID string
+	// ID of the model to use
+	Model string
+	// Amount of words to generate, the default amount of the guild if 0
+	Words int
+	// Cron expression for when to post, for example "0 9 * * *" for every day at 9:00
+	Schedule string
+	// Timezone of the schedule like "Europe/Helsinki", the local timezone if empty
+	Timezone string
+}
+
+// how old missed posts are still posted when the bot starts
+const scheduleCatchUp = 5 * time.Minute
+
+// how often the schedules are checked
+const scheduleCheckInterval = 15 * time.Second
+
+// scheduleState the times the schedules last posted, saved so nothing is posted twice after a restart
+type scheduleState struct {
+	// Time of the last post of each schedule by schedule ID
+	LastRuns map[string]time.Time
+
+	// path of the state file
+	filePath string
+	// lock for the last runs
+	mutex sync.Mutex
+}
+
+// loadScheduleState loads the schedule state from a file, a missing file is an empty state
+func loadScheduleState(statePath string) (*scheduleState, error) {
+	state := &scheduleState{LastRuns: make(map[string]time.Time), filePath: statePath}
+
+	stateContents, err := os.ReadFile(statePath)
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read schedule state file %s: %v", statePath, err)
+	}
+
+	if err := json.Unmarshal(stateContents, state); err != nil {
+		return nil, fmt.Errorf("failed to decode schedule state file %s: %v", statePath, err)
+	}
+
+	if state.LastRuns == nil {
+		state.LastRuns = make(map[string]time.Time)
+	}
+
+	return state, nil
+}
+
+// lastRun returns the time a schedule last posted
+func (state *scheduleState) lastRun(id string) (time.Time, bool) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	lastRun, ok := state.LastRuns[id]
+	return lastRun, ok
+}
+
+// setLastRun sets the time a schedule last posted & saves the state if save is set
+func (state *scheduleState) setLastRun(id string, lastRun time.Time, save bool) error {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	state.LastRuns[id] = lastRun
+
+	if save == false {
+		return nil
+	}
+
+	return WriteFileAtomic(state.filePath, 0660, false, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		if err := enc.Encode(state); err != nil {
+			return fmt.Errorf("failed to write schedule state to %s: %v", state.filePath, err)
+		}
+		return nil
+	})
+}
+
+// DefaultScheduleStateFile returns the schedule state file from the config, or schedule_state.json next to the executable
+func DefaultScheduleStateFile() (string, error) {
+	if LoadedConfig != nil && LoadedConfig.ScheduleStateFile != "" {
+		return LoadedConfig.ScheduleStateFile, nil
+	}
+
+	ed, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the executable directory for the schedule state file: %v", err)
+	}
+	return path.Join(path.Dir(ed), "schedule_state.json"), nil
+}
+
+// parseSchedule parses the cron expression & timezone of a schedule
+func parseSchedule(schedule ScheduleConfig) (*CronSchedule, *time.Location, error) {
+	cron, err := ParseCron(schedule.Schedule)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	location, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid timezone %s: %v", schedule.Timezone, err)
+	}
+
+	return cron, location, nil
+}
+
+// scheduleDue checks if a schedule should post now. Posts missed while the bot wasn't running are
+// only made if they were missed less than scheduleCatchUp before the bot started
+func scheduleDue(cron *CronSchedule, location *time.Location, lastRun time.Time, startTime time.Time, now time.Time) bool {
+	if earliest := startTime.Add(-scheduleCatchUp); lastRun.Before(earliest) {
+		lastRun = earliest
+	}
+
+	next := cron.Next(lastRun.In(location))
+	return next.IsZero() == false && now.Before(next) == false
+}
+
+// schedules returns a copy of the schedules in the config
+func schedules() []ScheduleConfig {
+	adminMutex.RLock()
+	defer adminMutex.RUnlock()
+
+	return append([]ScheduleConfig(nil), LoadedConfig.Schedules...)
+}
+
+// runScheduler posts the scheduled messages until stop is closed
+func runScheduler(s botSession, state *scheduleState, stop <-chan struct{}) {
+	startTime := time.Now()
+
+	for _, schedule := range schedules() {
+		if _, _, err := parseSchedule(schedule); err != nil {
+			logger.Warn("Schedule will not be posted", "schedule", schedule.ID, "error", err)
+		}
+	}
+
+	ticker := time.NewTicker(scheduleCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+
+		for _, schedule := range schedules() {
+			cron, location, err := parseSchedule(schedule)
+			if err != nil {
+				continue
+			}
+
+			// new schedules start from the time they are first seen
+			lastRun, ok := state.lastRun(schedule.ID)
+			if ok == false {
+				_ = state.setLastRun(schedule.ID, now, false)
+				continue
+			}
+
+			if scheduleDue(cron, location, lastRun, startTime, now) == false {
+				continue
+			}
+
+			// the bot is shutting down
+			if startTask() == false {
+				return
+			}
+
+			// save before posting so a crash can't cause a second post
+			if err := state.setLastRun(schedule.ID, now, true); err != nil {
+				logger.Error("Failed to save schedule state", "error", err)
+			}
+
+			if err := postScheduledMessage(s, schedule); err != nil {
+				logger.Error("Failed to post schedule", "schedule", schedule.ID, "error", err)
+			}
+			finishTask()
+		}
+	}
+}
+
+// postScheduledMessage generates text for a schedule & posts it to its channel
+func postScheduledMessage(s botSession, schedule ScheduleConfig) error {
+	model := getModel(schedule.Model)
+	if model == nil {
+		return fmt.Errorf("unknown model %s", schedule.Model)
+	}
+
+	if isModelDisabled(model.Info.ID) {
+		return fmt.Errorf("model %s is disabled", model.Info.ID)
+	}
+
+	if hasOptedOutContributors(model) {
+		return fmt.Errorf("model %s contains messages from users who have opted out", model.Info.ID)
+	}
+
+	// the access of the model can change after the schedule was added
+	guildID := s.channelGuildID(schedule.ChannelID)
+	if scheduleAllowed(schedule, guildID, model) == false {
+		return fmt.Errorf("model %s can't be used in channel %s", model.Info.ID, schedule.ChannelID)
+	}
+
+	wordModel, err := model.load()
+	if err != nil {
+		return fmt.Errorf("failed to load model %s: %v", model.Info.ID, err)
+	}
+
+	// use the settings of the channel's guild
+	settings := guildSettings(guildID)
+
+	amountOfWords := schedule.Words
+	if amountOfWords <= 0 {
+		amountOfWords = settings.DefaultWords
+	}
+	if amountOfWords > settings.MaxWords {
+		amountOfWords = settings.MaxWords
+	}
+
+	logger.Info("Posting schedule", "schedule", schedule.ID, "words", amountOfWords, "model", wordModel.ID)
+	generatedText, err := generateText(wordModel, &amountOfWords, nil)
+	if err != nil {
+		return fmt.Errorf("shutting down while generating text: %v", err)
+	}
+
+	// Discord refuses empty messages
+	if strings.TrimSpace(generatedText) == "" {
+		logger.Warn("Model has no words to post, skipping schedule", "schedule", schedule.ID, "model", wordModel.ID)
+		return nil
+	}
+
+	for _, message := range splitText(generatedText) {
+		if _, err := s.ChannelMessageSend(schedule.ChannelID, message); err != nil {
+			return fmt.Errorf("failed to send message to channel %s: %v", schedule.ChannelID, err)
+		}
+	}
+
+	return nil
+}
+
+// scheduleAllowed checks if the model of a schedule can be used in its channel & guild. Schedules have no user,
+// so models only allowed for some users or roles can't be scheduled
+func scheduleAllowed(schedule ScheduleConfig, guildID string, model *botModel) bool {
+	return canUseModelAs(model.Info.ID, "", schedule.ChannelID, guildID, nil) && guildHasModel(guildID, model)
+}
+
+// hurabotSchedule runs the schedule subcommands
+func hurabotSchedule(s botSession, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption) {
+	if len(options) < 1 {
+		return
+	}
+
+	switch options[0].Name {
+	case "add":
+		hurabotScheduleAdd(s, i, options[0].Options)
+	case "remove":
+		hurabotScheduleRemove(s, i, options[0].Options)
+	case "list":
+		hurabotScheduleList(s, i)
+	}
+}
+
+// hurabotScheduleAdd adds a schedule & saves it to the config file
+func hurabotScheduleAdd(s botSession, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption) {
+	var schedule ScheduleConfig
+
+	for _, option := range options {
+		switch option.Name {
+		case "id":
+			schedule.ID = option.StringValue()
+		case "channel":
+			schedule.ChannelID = option.ChannelValue(nil).ID
+		case "model":
+			schedule.Model = option.StringValue()
+		case "cron":
+			schedule.Schedule = option.StringValue()
+		case "words":
+			schedule.Words = int(option.IntValue())
+		case "timezone":
+			schedule.Timezone = option.StringValue()
+		}
+	}
+
+	if _, _, err := parseSchedule(schedule); err != nil {
+		respondEphemeral(s, i, "Invalid schedule: "+err.Error())
+		return
+	}
+
+	// save the ID of the model even if it was typed by name
+	model := getModel(schedule.Model)
+	if model == nil {
+		respondEphemeral(s, i, "Unknown model "+schedule.Model)
+		return
+	}
+	schedule.Model = model.Info.ID
+
+	// channels given as options are in the guild of the command
+	guildID := s.channelGuildID(schedule.ChannelID)
+	if guildID == "" {
+		guildID = i.GuildID
+	}
+	if scheduleAllowed(schedule, guildID, model) == false {
+		respondEphemeral(s, i, "Model "+model.Info.Name+" can't be used in <#"+schedule.ChannelID+">")
+		return
+	}
+
+	adminMutex.Lock()
+	for _, other := range LoadedConfig.Schedules {
+		if other.ID == schedule.ID {
+			adminMutex.Unlock()
+			respondEphemeral(s, i, "Schedule "+schedule.ID+" already exists")
+			return
+		}
+	}
+	LoadedConfig.Schedules = append(LoadedConfig.Schedules, schedule)
+	adminMutex.Unlock()
+
+	logger.Info("Added schedule", "schedule", schedule.ID, "channel", schedule.ChannelID, "model", schedule.Model, "cron", schedule.Schedule)
+
+	if err := ConfigUpdateFile(func(config *MainBotConfig) {
+		config.Schedules = append(config.Schedules, schedule)
+	}); err != nil {
+		logger.Error("Failed to save schedule to the config", "schedule", schedule.ID, "error", err)
+		respondEphemeral(s, i, "Schedule "+schedule.ID+" added, but saving it to the config failed: "+err.Error())
+		return
+	}
+
+	respondEphemeral(s, i, "Schedule "+schedule.ID+" added")
+}
+
+// hurabotScheduleRemove removes a schedule & saves the config file
+func hurabotScheduleRemove(s botSession, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption) {
+	id := options[0].StringValue()
+
+	removed := false
+	adminMutex.Lock()
+	LoadedConfig.Schedules, removed = removeSchedule(LoadedConfig.Schedules, id)
+	adminMutex.Unlock()
+
+	if removed == false {
+		respondEphemeral(s, i, "Unknown schedule "+id)
+		return
+	}
+
+	logger.Info("Removed schedule", "schedule", id)
+
+	if err := ConfigUpdateFile(func(config *MainBotConfig) {
+		config.Schedules, _ = removeSchedule(config.Schedules, id)
+	}); err != nil {
+		logger.Error("Failed to remove schedule from the config", "schedule", id, "error", err)
+		respondEphemeral(s, i, "Schedule "+id+" removed, but saving the config failed: "+err.Error())
+		return
+	}
+
+	respondEphemeral(s, i, "Schedule "+id+" removed")
+}
+
+// removeSchedule returns the schedules without the one with the ID, & whether it was found
+func removeSchedule(schedules []ScheduleConfig, id string) ([]ScheduleConfig, bool) {
+	kept := make([]ScheduleConfig, 0, len(schedules))
+	for _, schedule := range schedules {
+		if schedule.ID != id {
+			kept = append(kept, schedule)
+		}
+	}
+	return kept, len(kept) < len(schedules)
+}
+
+// hurabotScheduleList lists the schedules & when they post next
+func hurabotScheduleList(s botSession, i *discordgo.InteractionCreate) {
+	currentSchedules := schedules()
+
+	var list strings.Builder
+	fmt.Fprintf(&list, "%d schedules:\n", len(currentSchedules))
+
+	for _, schedule := range currentSchedules {
+		next := "invalid schedule"
+		if cron, location, err := parseSchedule(schedule); err == nil {
+			next = "next " + cron.Next(time.Now().In(location)).Format("2006-01-02 15:04 MST")
+		}
+
+		fmt.Fprintf(&list, "`%s` model `%s` to <#%s> at `%s` %s (%s)\n", schedule.ID, schedule.Model,
+			schedule.ChannelID, schedule.Schedule, schedule.Timezone, next)
+	}
+
+	respondEphemeral(s, i, splitText(list.String())[0])
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestScheduleDue(t *testing.T) {
+	cron, location, err := parseSchedule(ScheduleConfig{Schedule: "0 9 * * *", Timezone: "UTC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(day int, hour int, minute int) time.Time {
+		return time.Date(2022, 3, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name      string
+		lastRun   time.Time
+		startTime time.Time
+		now       time.Time
+		due       bool
+	}{
+		{"before the time", at(1, 8, 0), at(1, 7, 0), at(1, 8, 59), false},
+		{"at the time", at(1, 8, 0), at(1, 7, 0), at(1, 9, 0), true},
+		{"already posted", at(1, 9, 0), at(1, 7, 0), at(1, 9, 0), false},
+		{"posted before a restart", at(1, 9, 0), at(1, 9, 1), at(1, 9, 2), false},
+		{"missed just before starting", at(1, 8, 0), at(1, 9, 3), at(1, 9, 3), true},
+		{"missed long before starting", at(1, 8, 0), at(1, 12, 0), at(1, 12, 0), false},
+		{"next day after a long break", at(1, 8, 0), at(1, 12, 0), at(2, 9, 0), true},
+	}
+
+	for _, test := range tests {
+		if due := scheduleDue(cron, location, test.lastRun, test.startTime, test.now); due != test.due {
+			t.Errorf("%s: expected due to be %t, got %t", test.name, test.due, due)
+		}
+	}
+
+	if _, _, err := parseSchedule(ScheduleConfig{Schedule: "0 9 * * *", Timezone: "Not/A_Timezone"}); err == nil {
+		t.Error("invalid timezone was parsed")
+	}
+}
+
+func TestScheduleState(t *testing.T) {
+	testDir, err := os.MkdirTemp(os.TempDir(), "hurabotTestScheduleState")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statePath := path.Join(testDir, "schedule_state.json")
+
+	state, err := loadScheduleState(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lastRun := time.Date(2022, 3, 1, 9, 0, 0, 0, time.UTC)
+	if err := state.setLastRun("daily", lastRun, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// unsaved runs are not written
+	if err := state.setLastRun("new", lastRun, false); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err = loadScheduleState(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if savedRun, ok := state.lastRun("daily"); ok == false || savedRun.Equal(lastRun) == false {
+		t.Errorf("expected last run %v, got %v", lastRun, savedRun)
+	}
+
+	if _, ok := state.lastRun("new"); ok == true {
+		t.Error("unsaved last run was saved")
+	}
+
+	if err := os.RemoveAll(testDir); err != nil {
+		t.Logf("failed to remove the test directory %s: %v", testDir, err)
+	}
+}
+
+func TestScheduleModelAccess(t *testing.T) {
+	setupBotTest(t, &MainBotConfig{
+		MaxWords:     20,
+		DefaultWords: 3,
+		AdminUserIDs: []string{"30"},
+		ModelAccess:  map[string]ModelAccess{"test": {AllowedGuildIDs: []string{"21"}}},
+	}, "hello")
+
+	schedule := ScheduleConfig{ID: "daily", ChannelID: "10", Model: "test", Schedule: "0 9 * * *"}
+
+	s := newFakeSession()
+	s.channelGuilds = map[string]string{"10": "20", "11": "21"}
+
+	if err := postScheduledMessage(s, schedule); err == nil || len(s.messages["10"]) != 0 {
+		t.Errorf("expected the model to be refused in guild 20, got %v & %v", err, s.messages)
+	}
+
+	schedule.ChannelID = "11"
+	if err := postScheduledMessage(s, schedule); err != nil || len(s.messages["11"]) != 1 {
+		t.Errorf("expected the schedule to be posted in guild 21, got %v & %v", err, s.messages)
+	}
+
+	// models of other guilds can't be scheduled
+	s = newFakeSession()
+	interactionHandler(s, commandInteraction("hurabot", &discordgo.ApplicationCommandInteractionDataOption{
+		Name: "schedule",
+		Type: discordgo.ApplicationCommandOptionSubCommandGroup,
+		Options: []*discordgo.ApplicationCommandInteractionDataOption{{
+			Name: "add",
+			Type: discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandInteractionDataOption{
+				stringOption("id", "daily"),
+				{Name: "channel", Type: discordgo.ApplicationCommandOptionChannel, Value: "10"},
+				stringOption("model", "test"),
+				stringOption("cron", "0 9 * * *"),
+			},
+		}},
+	}))
+
+	if len(s.responses) != 1 || s.responses[0].Data.Content != "Model Test can't be used in <#10>" {
+		t.Errorf("expected the schedule to be refused, got %+v", s.responses)
+	}
+	if len(LoadedConfig.Schedules) != 0 {
+		t.Errorf("expected no schedules to be added, got %+v", LoadedConfig.Schedules)
+	}
+}
+
+func TestScheduleEmptyModel(t *testing.T) {
+	setupBotTest(t, &MainBotConfig{MaxWords: 20, DefaultWords: 3}, "hello")
+
+	modelsMutex.Lock()
+	botModels[0].live.Words = nil
+	modelsMutex.Unlock()
+
+	s := newFakeSession()
+	if err := postScheduledMessage(s, ScheduleConfig{ID: "daily", ChannelID: "10", Model: "test"}); err != nil {
+		t.Errorf("expected the schedule of an empty model to be skipped, got %v", err)
+	}
+	if len(s.messages["10"]) != 0 {
+		t.Errorf("expected no messages from an empty model, got %v", s.messages)
+	}
+}
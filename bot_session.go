@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+)
+
+// messageFetcher the parts of a Discord session used for fetching the messages of a channel
+type messageFetcher interface {
+	ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string) ([]*discordgo.Message, error)
+	AddHandler(handler interface{}) func()
+}
+
+// botSession the parts of a Discord session used by the handlers, so they can be tested without connecting to Discord
+type botSession interface {
+	messageFetcher
+
+	// userID returns the ID of the bot user
+	userID() string
+	// channelGuildID returns the ID of the guild of a channel, empty if it's not known
+	channelGuildID(channelID string) string
+
+	InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse) error
+	InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit) (*discordgo.Message, error)
+	FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, data *discordgo.WebhookParams) (*discordgo.Message, error)
+	FollowupMessageDelete(interaction *discordgo.Interaction, messageID string) error
+	InteractionResponseDelete(interaction *discordgo.Interaction) error
+
+	ApplicationCommandCreate(appID string, guildID string, cmd *discordgo.ApplicationCommand) (*discordgo.ApplicationCommand, error)
+	ApplicationCommands(appID, guildID string) ([]*discordgo.ApplicationCommand, error)
+	ApplicationCommandDelete(appID, guildID, cmdID string) error
+	ApplicationCommandBulkOverwrite(appID string, guildID string, commands []*discordgo.ApplicationCommand) ([]*discordgo.ApplicationCommand, error)
+
+	ChannelMessage(channelID, messageID string) (*discordgo.Message, error)
+	ChannelMessageSend(channelID string, content string) (*discordgo.Message, error)
+	ChannelMessageSendReply(channelID string, content string, reference *discordgo.MessageReference) (*discordgo.Message, error)
+}
+
+// discordSession a botSession connected to Discord
+type discordSession struct {
+	*discordgo.Session
+}
+
+// userID returns the ID of the bot user
+func (s discordSession) userID() string {
+	return s.State.User.ID
+}
+
+// channelGuildID returns the ID of the guild of a channel from the state cache, empty if it's not known
+func (s discordSession) channelGuildID(channelID string) string {
+	if channel, err := s.State.Channel(channelID); err == nil {
+		return channel.GuildID
+	}
+	return ""
+}
+
+// InteractionRespond responds to an interaction & marks it as responded to for the health checks
+func (s discordSession) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse) error {
+	if err := s.Session.InteractionRespond(interaction, resp); err != nil {
+		return err
+	}
+	recordInteraction()
+	return nil
+}
+
+// newRESTSession makes a session for using the API without connecting to the gateway
+func newRESTSession(token string) (discordSession, error) {
+	bot, err := discordgo.New("Bot " + token)
+	if err != nil {
+		return discordSession{}, fmt.Errorf("failed to create Discord session: %v", err)
+	}
+
+	// the bot user is only known after connecting, so it's fetched separately
+	user, err := bot.User("@me")
+	if err != nil {
+		return discordSession{}, fmt.Errorf("failed to get the bot user: %v", err)
+	}
+	bot.State.User = user
+
+	return discordSession{bot}, nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// default seconds to wait for running commands when shutting down
+const defaultShutdownTimeout = 10
+
+var (
+	// cancelled when the bot starts shutting down, running handlers stop what they're doing
+	botContext = context.Background()
+	// lock for the task count & shutting down at the same time
+	tasksMutex sync.Mutex
+	// amount of handlers & background tasks still running
+	runningTaskCount int
+	// closed when the running tasks have finished, nil if nothing is waiting for them
+	tasksIdle chan struct{}
+)
+
+// startTask marks a handler as running so shutting down waits for it. Returns false if the bot is shutting down,
+// otherwise finishTask has to be called when the handler is done
+func startTask() bool {
+	tasksMutex.Lock()
+	defer tasksMutex.Unlock()
+
+	if botContext.Err() != nil {
+		return false
+	}
+
+	runningTaskCount++
+	return true
+}
+
+// finishTask marks a handler started with startTask as done
+func finishTask() {
+	tasksMutex.Lock()
+	defer tasksMutex.Unlock()
+
+	runningTaskCount--
+	if runningTaskCount == 0 && tasksIdle != nil {
+		close(tasksIdle)
+		tasksIdle = nil
+	}
+}
+
+// stopTasks cancels botContext so no new tasks are started & the running ones stop
+func stopTasks(cancel context.CancelFunc) {
+	tasksMutex.Lock()
+	defer tasksMutex.Unlock()
+
+	cancel()
+}
+
+// waitForTasks waits for the running tasks to finish, returns false if the timeout ran out first
+func waitForTasks(timeout time.Duration) bool {
+	tasksMutex.Lock()
+	if runningTaskCount == 0 {
+		tasksMutex.Unlock()
+		return true
+	}
+	if tasksIdle == nil {
+		tasksIdle = make(chan struct{})
+	}
+	idle := tasksIdle
+	tasksMutex.Unlock()
+
+	select {
+	case <-idle:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// shutdownTimeout returns how long to wait for running tasks when shutting down
+func shutdownTimeout() time.Duration {
+	if LoadedConfig.ShutdownTimeout <= 0 {
+		return defaultShutdownTimeout * time.Second
+	}
+	return time.Duration(LoadedConfig.ShutdownTimeout) * time.Second
+}
+
+// shutdownBot stops the running tasks, saves the live model & removes the commands.
+// Cleanup continues after errors & the errors are returned together
+func shutdownBot(s botSession, cancel context.CancelFunc) error {
+	stopTasks(cancel)
+
+	logger.Info("Waiting for running commands to finish")
+	if waitForTasks(shutdownTimeout()) == false {
+		logger.Warn("Commands still running, shutting down anyway", "timeout", shutdownTimeout())
+	}
+
+	failures := make([]string, 0)
+
+	if liveModel != nil {
+		if err := saveLiveModel(); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+
+	if usageStore != nil {
+		if err := saveUsage(); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+
+	// persistent commands stay usable while the bot restarts
+	if LoadedConfig.PersistentCommands == false {
+		logger.Info("Removing commands")
+		if err := removeCommands(s); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("errors while shutting down: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/bwmarrin/discordgo"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupShutdownTest gives the test a context of its own to cancel
+func setupShutdownTest(t *testing.T) context.CancelFunc {
+	ctx, cancel := context.WithCancel(context.Background())
+	botContext = ctx
+
+	t.Cleanup(func() {
+		cancel()
+		botContext = context.Background()
+	})
+	return cancel
+}
+
+func TestShutdownBot(t *testing.T) {
+	setupBotTest(t, &MainBotConfig{GuildID: "main", MaxWords: 20, ShutdownTimeout: 5}, "hello")
+	cancel := setupShutdownTest(t)
+
+	s := newFakeSession()
+	s.commands["main"] = []*discordgo.ApplicationCommand{{ID: "1", Name: "generate-text"}, {ID: "2", Name: "hurabot"}}
+
+	// a running command is waited for
+	if startTask() == false {
+		t.Fatal("task couldn't be started before shutting down")
+	}
+	finished := false
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		finished = true
+		finishTask()
+	}()
+
+	if err := shutdownBot(s, cancel); err != nil {
+		t.Fatal(err)
+	}
+
+	if finished == false {
+		t.Error("shutting down didn't wait for the running task")
+	}
+	if len(s.commands["main"]) != 0 {
+		t.Errorf("expected the commands to be removed, got %d", len(s.commands["main"]))
+	}
+	if startTask() == true {
+		finishTask()
+		t.Error("task was started after shutting down")
+	}
+
+	// commands get a reply instead of being run
+	s = newFakeSession()
+	interactionHandler(s, commandInteraction("generate-text", stringOption("model", "test")))
+
+	if len(s.responses) != 1 || s.responses[0].Data.Content != "The bot is shutting down, try again later" {
+		t.Errorf("expected a shutting down response, got %+v", s.responses)
+	}
+}
+
+func TestShutdownBotErrors(t *testing.T) {
+	setupBotTest(t, &MainBotConfig{GuildID: "main", MaxWords: 20}, "hello")
+	cancel := setupShutdownTest(t)
+
+	s := newFakeSession()
+	s.commands["main"] = []*discordgo.ApplicationCommand{{ID: "1", Name: "generate-text"}, {ID: "2", Name: "hurabot"}}
+	s.deleteErr = errors.New("delete failed")
+
+	// every command is tried & the errors are returned instead of stopping at the first one
+	err := shutdownBot(s, cancel)
+	if err == nil {
+		t.Fatal("expected an error when commands can't be deleted")
+	}
+	if strings.Contains(err.Error(), "generate-text") == false || strings.Contains(err.Error(), "hurabot") == false {
+		t.Errorf("expected errors for both commands, got %v", err)
+	}
+}
+
+func TestWaitForTasksTimeout(t *testing.T) {
+	setupShutdownTest(t)
+
+	if startTask() == false {
+		t.Fatal("task couldn't be started")
+	}
+
+	if waitForTasks(10*time.Millisecond) == true {
+		t.Error("waiting returned before the task finished")
+	}
+
+	finishTask()
+
+	if waitForTasks(time.Second) == false {
+		t.Error("waiting timed out after the task finished")
+	}
+}
+
+func TestShutdownCancelsGeneration(t *testing.T) {
+	setupBotTest(t, &MainBotConfig{MaxWords: 20, DefaultWords: 5}, "hello")
+	cancel := setupShutdownTest(t)
+
+	wordModel, err := getModel("test").load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the generation waits for the model like it would for building a big chain
+	wordModel.mutex.Lock()
+	done := make(chan struct{})
+	s := newFakeSession()
+	go func() {
+		interactionHandler(s, commandInteraction("generate-text", stringOption("model", "test")))
+		close(done)
+	}()
+
+	for waitForTasks(0) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	wordModel.mutex.Unlock()
+	<-done
+
+	if len(s.edits) != 1 || s.edits[0].Content != "The bot is shutting down, try again later" {
+		t.Errorf("expected the generation to be cancelled, got %+v", s.edits)
+	}
+}
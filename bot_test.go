@@ -0,0 +1,645 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/bwmarrin/discordgo"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSession a botSession that records what the handlers send instead of sending it to Discord
+type fakeSession struct {
+	mutex sync.Mutex
+
+	// interaction responses sent
+	responses []*discordgo.InteractionResponse
+	// edits of the interaction responses
+	edits []*discordgo.WebhookEdit
+	// followup messages sent
+	followups []*discordgo.WebhookParams
+	// commands registered by guild ID
+	commands map[string][]*discordgo.ApplicationCommand
+	// messages sent by channel ID
+	messages map[string][]string
+	// IDs of the followup messages deleted
+	deletedFollowups []string
+	// amount of interaction responses deleted
+	deletedResponses int
+	// guild IDs of the channels by channel ID
+	channelGuilds map[string]string
+	// messages that can be fetched by message ID
+	knownMessages map[string]*discordgo.Message
+
+	// error returned when editing a response
+	editErr error
+	// error returned when deleting a command
+	deleteErr error
+	// amount of bulk overwrites
+	overwrites int
+}
+
+func newFakeSession() *fakeSession {
+	return &fakeSession{
+		commands: make(map[string][]*discordgo.ApplicationCommand),
+		messages: make(map[string][]string),
+	}
+}
+
+func (s *fakeSession) userID() string {
+	return "bot"
+}
+
+func (s *fakeSession) channelGuildID(channelID string) string {
+	return s.channelGuilds[channelID]
+}
+
+func (s *fakeSession) AddHandler(handler interface{}) func() {
+	return func() {}
+}
+
+func (s *fakeSession) ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string) ([]*discordgo.Message, error) {
+	return nil, nil
+}
+
+func (s *fakeSession) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.responses = append(s.responses, resp)
+	return nil
+}
+
+func (s *fakeSession) InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit) (*discordgo.Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.editErr != nil {
+		return nil, s.editErr
+	}
+
+	s.edits = append(s.edits, newresp)
+
+	// the response of a component interaction is the message the component is on
+	messageID := "response"
+	if interaction.Message != nil {
+		messageID = interaction.Message.ID
+	}
+	return &discordgo.Message{ID: messageID, Content: newresp.Content}, nil
+}
+
+func (s *fakeSession) FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, data *discordgo.WebhookParams) (*discordgo.Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.followups = append(s.followups, data)
+	return &discordgo.Message{ID: "followup" + strconv.Itoa(len(s.followups)), Content: data.Content}, nil
+}
+
+func (s *fakeSession) FollowupMessageDelete(interaction *discordgo.Interaction, messageID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.deletedFollowups = append(s.deletedFollowups, messageID)
+	return nil
+}
+
+func (s *fakeSession) InteractionResponseDelete(interaction *discordgo.Interaction) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.deletedResponses++
+	return nil
+}
+
+func (s *fakeSession) ApplicationCommandCreate(appID string, guildID string, cmd *discordgo.ApplicationCommand) (*discordgo.ApplicationCommand, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.commands[guildID] = append(s.commands[guildID], cmd)
+	return cmd, nil
+}
+
+func (s *fakeSession) ApplicationCommands(appID, guildID string) ([]*discordgo.ApplicationCommand, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]*discordgo.ApplicationCommand(nil), s.commands[guildID]...), nil
+}
+
+func (s *fakeSession) ApplicationCommandDelete(appID, guildID, cmdID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.deleteErr != nil {
+		return s.deleteErr
+	}
+
+	for index, command := range s.commands[guildID] {
+		if command.ID == cmdID {
+			s.commands[guildID] = append(s.commands[guildID][:index], s.commands[guildID][index+1:]...)
+			return nil
+		}
+	}
+	return errors.New("unknown command " + cmdID)
+}
+
+func (s *fakeSession) ApplicationCommandBulkOverwrite(appID string, guildID string, commands []*discordgo.ApplicationCommand) ([]*discordgo.ApplicationCommand, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.overwrites++
+
+	// Discord returns the commands with IDs & types set
+	encoded, err := json.Marshal(commands)
+	if err != nil {
+		return nil, err
+	}
+	var registered []*discordgo.ApplicationCommand
+	if err := json.Unmarshal(encoded, &registered); err != nil {
+		return nil, err
+	}
+
+	for index, command := range registered {
+		if command.ID == "" {
+			command.ID = strconv.Itoa(index + 1)
+		}
+		if command.Type == 0 {
+			command.Type = discordgo.ChatApplicationCommand
+		}
+	}
+
+	s.commands[guildID] = registered
+	return registered, nil
+}
+
+func (s *fakeSession) ChannelMessage(channelID, messageID string) (*discordgo.Message, error) {
+	if message, ok := s.knownMessages[messageID]; ok {
+		return message, nil
+	}
+	return nil, errors.New("unknown message " + messageID)
+}
+
+func (s *fakeSession) ChannelMessageSend(channelID string, content string) (*discordgo.Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.messages[channelID] = append(s.messages[channelID], content)
+	return &discordgo.Message{ChannelID: channelID, Content: content}, nil
+}
+
+func (s *fakeSession) ChannelMessageSendReply(channelID string, content string, reference *discordgo.MessageReference) (*discordgo.Message, error) {
+	return s.ChannelMessageSend(channelID, content)
+}
+
+// setupBotTest sets a config & an in-memory model with one word for testing the handlers
+func setupBotTest(t *testing.T, config *MainBotConfig, word string) {
+	oldConfig := LoadedConfig
+	oldLogger := logger
+
+	modelsMutex.Lock()
+	oldModels, oldFileStates := botModels, modelFileStates
+	botModels = []*botModel{{
+		Info: &ModelInfo{ID: "test", Name: "Test"},
+		live: &WordModel{ID: "test", Name: "Test", Words: []string{word}},
+	}}
+	modelsMutex.Unlock()
+
+	LoadedConfig = config
+	logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	initRateLimiters()
+
+	t.Cleanup(func() {
+		LoadedConfig = oldConfig
+		logger = oldLogger
+
+		modelsMutex.Lock()
+		botModels, modelFileStates = oldModels, oldFileStates
+		modelsMutex.Unlock()
+
+		userRateLimiter, channelRateLimiter, guildRateLimiter = nil, nil, nil
+	})
+}
+
+// commandInteraction makes an interaction of a command sent from a guild
+func commandInteraction(name string, options ...*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionCreate {
+	return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		Type:      discordgo.InteractionApplicationCommand,
+		ChannelID: "10",
+		GuildID:   "20",
+		Member:    &discordgo.Member{User: &discordgo.User{ID: "30", Username: "tester"}},
+		Data: discordgo.ApplicationCommandInteractionData{
+			Name:    name,
+			Options: options,
+		},
+	}}
+}
+
+func stringOption(name string, value string) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{Name: name, Type: discordgo.ApplicationCommandOptionString, Value: value}
+}
+
+func intOption(name string, value int) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{Name: name, Type: discordgo.ApplicationCommandOptionInteger, Value: float64(value)}
+}
+
+func TestGenerateTextCommand(t *testing.T) {
+	setupBotTest(t, &MainBotConfig{MaxWords: 20, DefaultWords: 10}, "hello")
+
+	s := newFakeSession()
+	interactionHandler(s, commandInteraction("generate-text", stringOption("model", "test"), intOption("words", 500)))
+
+	if len(s.responses) != 1 || s.responses[0].Data.Content != "Generating text with 20 words using model Test" {
+		t.Fatalf("expected a response with the amount of words limited to 20, got %+v", s.responses)
+	}
+	if len(s.edits) != 1 || strings.Contains(s.edits[0].Content, "\n\nhello") == false {
+		t.Errorf("expected the response to be edited with the generated text, got %+v", s.edits)
+	}
+	if len(s.followups) != 0 {
+		t.Errorf("expected no followups for a short text, got %d", len(s.followups))
+	}
+
+	// models are found by name too & the words default to the config
+	s = newFakeSession()
+	interactionHandler(s, commandInteraction("generate-text", stringOption("model", "TEST")))
+
+	if len(s.responses) != 1 || s.responses[0].Data.Content != "Generating text with 10 words using model Test" {
+		t.Errorf("expected a response with the default amount of words, got %+v", s.responses)
+	}
+}
+
+func TestGenerateTextCommandSplitsMessages(t *testing.T) {
+	setupBotTest(t, &MainBotConfig{MaxWords: 20}, strings.Repeat("a", 4500))
+
+	s := newFakeSession()
+	interactionHandler(s, commandInteraction("generate-text", stringOption("model", "test")))
+
+	if len(s.edits) != 1 || len(s.followups) != 2 {
+		t.Fatalf("expected the text to be sent as 1 edit & 2 followups, got %d & %d", len(s.edits), len(s.followups))
+	}
+
+	if length := len([]rune(s.edits[0].Content)); length != 2000 {
+		t.Errorf("expected the first message to be 2000 characters, got %d", length)
+	}
+	if strings.HasPrefix(s.followups[1].Content, "aaa") == false {
+		t.Error("expected the last followup to contain the end of the text")
+	}
+}
+
+func TestGenerateTextCommandErrors(t *testing.T) {
+	setupBotTest(t, &MainBotConfig{MaxWords: 20}, "hello")
+
+	s := newFakeSession()
+	interactionHandler(s, commandInteraction("generate-text", stringOption("model", "missing")))
+
+	if len(s.responses) != 1 || s.responses[0].Data.Content != "Unknown model missing" ||
+		s.responses[0].Data.Flags != uint64(discordgo.MessageFlagsEphemeral) {
+		t.Errorf("expected an ephemeral unknown model response, got %+v", s.responses)
+	}
+
+	disabledModels["test"] = true
+	s = newFakeSession()
+	interactionHandler(s, commandInteraction("generate-text", stringOption("model", "test")))
+	delete(disabledModels, "test")
+
+	if len(s.responses) != 1 || s.responses[0].Data.Content != "Model Test is disabled" {
+		t.Errorf("expected a disabled model response, got %+v", s.responses)
+	}
+
+	// a failed edit is reported with a followup
+	s = newFakeSession()
+	s.editErr = errors.New("edit failed")
+	interactionHandler(s, commandInteraction("generate-text", stringOption("model", "test")))
+
+	if len(s.followups) != 1 || s.followups[0].Content != "Something went wrong" {
+		t.Errorf("expected a followup telling something went wrong, got %+v", s.followups)
+	}
+}
+
+func TestInteractionHandlerChecks(t *testing.T) {
+	setupBotTest(t, &MainBotConfig{
+		MaxWords:      20,
+		UserRateLimit: RateLimit{Commands: 1, Seconds: 60},
+		Guilds:        map[string]GuildConfig{"20": {AllowedChannelIDs: []string{"11"}}},
+	}, "hello")
+
+	s := newFakeSession()
+	interactionHandler(s, commandInteraction("generate-text", stringOption("model", "test")))
+
+	if len(s.responses) != 1 || s.responses[0].Data.Content != "Commands can't be used in this channel" {
+		t.Fatalf("expected the command to be refused in the channel, got %+v", s.responses)
+	}
+
+	LoadedConfig.Guilds = nil
+
+	s = newFakeSession()
+	interactionHandler(s, commandInteraction("generate-text", stringOption("model", "test")))
+	interactionHandler(s, commandInteraction("generate-text", stringOption("model", "test")))
+
+	if len(s.responses) != 2 || strings.HasPrefix(s.responses[1].Data.Content, "Slow down!") == false {
+		t.Errorf("expected the second command to be rate limited, got %+v", s.responses)
+	}
+
+	// unknown commands are ignored
+	s = newFakeSession()
+	interactionHandler(s, commandInteraction("unknown"))
+	if len(s.responses) != 0 {
+		t.Errorf("expected no response to an unknown command, got %+v", s.responses)
+	}
+}
+
+// replyInteraction makes an interaction of the Reply as model command used on a message
+func replyInteraction(message *discordgo.Message) *discordgo.InteractionCreate {
+	interaction := commandInteraction("Reply as model")
+	interaction.Data = discordgo.ApplicationCommandInteractionData{
+		Name:     "Reply as model",
+		TargetID: "40",
+		Resolved: &discordgo.ApplicationCommandInteractionDataResolved{
+			Messages: map[string]*discordgo.Message{message.ID: message},
+		},
+	}
+	return interaction
+}
+
+func TestReplyAsModelCommand(t *testing.T) {
+	setupBotTest(t, &MainBotConfig{MaxWords: 20, DefaultWords: 5, DefaultModel: "test"}, "hello")
+
+	message := &discordgo.Message{ID: "40", ChannelID: "10", GuildID: "20", Content: "hello there"}
+
+	// the default model replies right away
+	s := newFakeSession()
+	interactionHandler(s, replyInteraction(message))
+
+	if len(s.responses) != 1 || s.responses[0].Type != discordgo.InteractionResponseDeferredChannelMessageWithSource ||
+		s.responses[0].Data.Flags != uint64(discordgo.MessageFlagsEphemeral) {
+		t.Fatalf("expected an ephemeral deferred response, got %+v", s.responses)
+	}
+	if len(s.messages["10"]) != 1 || strings.HasPrefix(s.messages["10"][0], "hello") == false {
+		t.Errorf("expected a reply to the message, got %v", s.messages)
+	}
+	if len(s.edits) != 1 || s.edits[0].Content != "Replied with model Test" {
+		t.Errorf("expected the response to tell the reply was sent, got %+v", s.edits)
+	}
+
+	// a message that isn't resolved can't be replied to
+	s = newFakeSession()
+	interaction := replyInteraction(message)
+	interaction.Data = discordgo.ApplicationCommandInteractionData{
+		Name:     "Reply as model",
+		TargetID: "41",
+		Resolved: &discordgo.ApplicationCommandInteractionDataResolved{},
+	}
+	interactionHandler(s, interaction)
+
+	if len(s.responses) != 1 || s.responses[0].Data.Content != "Message not found" || len(s.messages) != 0 {
+		t.Errorf("expected the missing message to be reported, got %+v", s.responses)
+	}
+
+	// empty models have nothing to reply with
+	modelsMutex.Lock()
+	botModels[0].live.Words = nil
+	modelsMutex.Unlock()
+
+	s = newFakeSession()
+	interactionHandler(s, replyInteraction(message))
+
+	if len(s.messages) != 0 || len(s.edits) != 1 || s.edits[0].Content != "Model Test has no words to reply with yet" {
+		t.Errorf("expected no reply from an empty model, got %v & %+v", s.messages, s.edits)
+	}
+}
+
+func TestReplyAsModelSelect(t *testing.T) {
+	setupBotTest(t, &MainBotConfig{MaxWords: 20, DefaultWords: 5}, "hello")
+
+	message := &discordgo.Message{ID: "40", ChannelID: "10", GuildID: "20", Content: "hello there"}
+
+	// without a default model the model is chosen from a menu
+	s := newFakeSession()
+	interactionHandler(s, replyInteraction(message))
+
+	if len(s.responses) != 1 || len(s.responses[0].Data.Components) != 1 {
+		t.Fatalf("expected a response with a select menu, got %+v", s.responses)
+	}
+	menu := s.responses[0].Data.Components[0].(discordgo.ActionsRow).Components[0].(discordgo.SelectMenu)
+	if len(menu.Options) != 1 || menu.Options[0].Value != "test" {
+		t.Errorf("expected the usable model as the only option, got %+v", menu.Options)
+	}
+
+	selectInteraction := func(modelID string) *discordgo.InteractionCreate {
+		return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+			Type:      discordgo.InteractionMessageComponent,
+			ChannelID: "10",
+			GuildID:   "20",
+			Member:    &discordgo.Member{User: &discordgo.User{ID: "30", Username: "tester"}},
+			Message:   &discordgo.Message{ID: "menu"},
+			Data:      discordgo.MessageComponentInteractionData{CustomID: menu.CustomID, Values: []string{modelID}},
+		}}
+	}
+
+	// the message to reply to is found from the custom ID of the menu
+	s = newFakeSession()
+	s.knownMessages = map[string]*discordgo.Message{"40": message}
+	interactionHandler(s, selectInteraction("test"))
+
+	if len(s.messages["10"]) != 1 || len(s.edits) != 1 || s.edits[0].Content != "Replied with model Test" {
+		t.Errorf("expected a reply with the chosen model, got %v & %+v", s.messages, s.edits)
+	}
+
+	// models can be disabled after the menu was shown
+	disabledModels["test"] = true
+	s = newFakeSession()
+	s.knownMessages = map[string]*discordgo.Message{"40": message}
+	interactionHandler(s, selectInteraction("test"))
+	delete(disabledModels, "test")
+
+	if len(s.responses) != 1 || s.responses[0].Data.Content != "Model test can't be used here" || len(s.messages) != 0 {
+		t.Errorf("expected the disabled model to be refused, got %+v", s.responses)
+	}
+
+	// the message can be deleted after the menu was shown
+	s = newFakeSession()
+	interactionHandler(s, selectInteraction("test"))
+
+	if len(s.responses) != 1 || s.responses[0].Data.Content != "Message not found" {
+		t.Errorf("expected the missing message to be reported, got %+v", s.responses)
+	}
+}
+
+func TestHurabotCommandNeedsAdmin(t *testing.T) {
+	setupBotTest(t, &MainBotConfig{MaxWords: 20, AdminUserIDs: []string{"admin"}}, "hello")
+
+	s := newFakeSession()
+	interactionHandler(s, commandInteraction("hurabot", &discordgo.ApplicationCommandInteractionDataOption{
+		Name: "list",
+		Type: discordgo.ApplicationCommandOptionSubCommand,
+	}))
+
+	if len(s.responses) != 1 || s.responses[0].Data.Content != "Only admins can use this command" {
+		t.Errorf("expected the command to be refused from a non-admin, got %+v", s.responses)
+	}
+}
+
+func TestHurabotServerAdmins(t *testing.T) {
+	setupBotTest(t, &MainBotConfig{MaxWords: 20}, "hello")
+
+	list := func() string {
+		interaction := commandInteraction("hurabot", &discordgo.ApplicationCommandInteractionDataOption{
+			Name: "list",
+			Type: discordgo.ApplicationCommandOptionSubCommand,
+		})
+		interaction.Member.Permissions = discordgo.PermissionAdministrator
+
+		s := newFakeSession()
+		interactionHandler(s, interaction)
+		if len(s.responses) != 1 {
+			t.Fatalf("expected a response, got %+v", s.responses)
+		}
+		return s.responses[0].Data.Content
+	}
+
+	// global commands can be used by the admins of any guild
+	if response := list(); strings.HasPrefix(response, "No admins are set for the bot") == false {
+		t.Errorf("expected server admins to be refused without admins set, got %s", response)
+	}
+
+	// commands in only the guild of the owner can be used by its admins
+	LoadedConfig.GuildID = "20"
+	if response := list(); strings.HasSuffix(response, "models found:\n`test` Test\n") == false {
+		t.Errorf("expected a server admin of GuildID to list the models, got %s", response)
+	}
+
+	LoadedConfig.GuildID = "21"
+	if response := list(); response != "Only admins can use this command" {
+		t.Errorf("expected server admins of other guilds to be refused, got %s", response)
+	}
+}
+
+func TestHurabotGuildAdminRoles(t *testing.T) {
+	setupBotTest(t, &MainBotConfig{
+		MaxWords:     20,
+		AdminUserIDs: []string{"admin"},
+		Guilds:       map[string]GuildConfig{"20": {AdminRoleIDs: []string{"moderator"}}},
+	}, "hello")
+
+	subcommand := func(name string, options ...*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionCreate {
+		interaction := commandInteraction("hurabot", &discordgo.ApplicationCommandInteractionDataOption{
+			Name:    name,
+			Type:    discordgo.ApplicationCommandOptionSubCommand,
+			Options: options,
+		})
+		interaction.Member.Roles = []string{"moderator"}
+		return interaction
+	}
+
+	// the admin roles of a guild can change the guild
+	s := newFakeSession()
+	interactionHandler(s, subcommand("maxwords", intOption("words", 10)))
+
+	if len(s.responses) != 1 || s.responses[0].Data.Content != "Maximum amount of words changed to 10" {
+		t.Errorf("expected a guild admin to change the maximum of the guild, got %+v", s.responses)
+	}
+	if maxWords := guildSettings("20").MaxWords; maxWords != 10 {
+		t.Errorf("expected maximum 10 words in the guild, got %d", maxWords)
+	}
+
+	// but not the models used in every guild
+	for _, name := range []string{"reload", "list"} {
+		s = newFakeSession()
+		interactionHandler(s, subcommand(name))
+
+		if len(s.responses) != 1 || s.responses[0].Data.Content != "Only the admins of the bot can use this command" {
+			t.Errorf("expected %s to be refused from a guild admin, got %+v", name, s.responses)
+		}
+	}
+}
+
+func TestHurabotAutocomplete(t *testing.T) {
+	setupBotTest(t, &MainBotConfig{
+		AdminUserIDs: []string{"30"},
+		ModelAccess:  map[string]ModelAccess{"private": {AllowedGuildIDs: []string{"21"}}},
+	}, "hello")
+
+	modelsMutex.Lock()
+	botModels = append(botModels, &botModel{
+		Info: &ModelInfo{ID: "private", Name: "Private"},
+		live: &WordModel{ID: "private", Name: "Private", Words: []string{"secret"}},
+	})
+	modelsMutex.Unlock()
+
+	autocomplete := func(userID string) []*discordgo.ApplicationCommandOptionChoice {
+		interaction := commandInteraction("hurabot", &discordgo.ApplicationCommandInteractionDataOption{
+			Name: "prune",
+			Type: discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandInteractionDataOption{
+				{Name: "model", Type: discordgo.ApplicationCommandOptionString, Value: "", Focused: true},
+			},
+		})
+		interaction.Type = discordgo.InteractionApplicationCommandAutocomplete
+		interaction.Member.User.ID = userID
+
+		s := newFakeSession()
+		interactionHandler(s, interaction)
+		if len(s.responses) != 1 {
+			t.Fatalf("expected autocomplete choices, got %+v", s.responses)
+		}
+		return s.responses[0].Data.Choices
+	}
+
+	// models restricted to other guilds aren't suggested
+	if choices := autocomplete("30"); len(choices) != 1 || choices[0].Value != "test" {
+		t.Errorf("expected only the usable model to be suggested to an admin, got %+v", choices)
+	}
+
+	if choices := autocomplete("31"); len(choices) != 0 {
+		t.Errorf("expected no models to be suggested to a non-admin, got %+v", choices)
+	}
+}
+
+func TestRegisterCommand(t *testing.T) {
+	setupBotTest(t, &MainBotConfig{
+		GuildID:  "main",
+		MaxWords: 200,
+		Guilds:   map[string]GuildConfig{"small": {MaxWords: 20}},
+	}, "hello")
+
+	s := newFakeSession()
+	if err := registerCommand(s, botCommands[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.commands["main"]) != 1 || len(s.commands["small"]) != 1 {
+		t.Fatalf("expected the command in guilds main & small, got %v", s.commands)
+	}
+	if maxValue := s.commands["small"][0].Options[1].MaxValue; maxValue != 20 {
+		t.Errorf("expected a maximum of 20 words in guild small, got %v", maxValue)
+	}
+	if maxValue := s.commands["main"][0].Options[1].MaxValue; maxValue != 200 {
+		t.Errorf("expected a maximum of 200 words in guild main, got %v", maxValue)
+	}
+}
+
+func TestSplitText(t *testing.T) {
+	tests := []struct {
+		length   int
+		messages int
+	}{
+		{0, 1},
+		{2000, 1},
+		{2001, 2},
+		{4500, 3},
+	}
+
+	for _, test := range tests {
+		messages := splitText(strings.Repeat("ä", test.length))
+		if len(messages) != test.messages {
+			t.Errorf("expected %d characters to be split to %d messages, got %d", test.length, test.messages, len(messages))
+		}
+		if strings.Join(messages, "") != strings.Repeat("ä", test.length) {
+			t.Errorf("text of %d characters changed when split", test.length)
+		}
+	}
+}
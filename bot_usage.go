@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"strings"
+	"time"
+)
+
+// UsageStatsConfig settings for counting who generates text with which models
+type UsageStatsConfig struct {
+	// Count the generations & save the counts
+	Enabled bool
+	// File where the counts are saved, usage.json next to the executable if empty
+	File string
+	// Save hashes of the user IDs instead of the IDs, IDs already saved are hashed too
+	Anonymize bool
+	// File where the salt for hashing the user IDs is saved, the usage file with .salt added if empty.
+	// Anyone with both files can find the users of the hashes
+	SaltFile string
+	// How often in seconds to save the counts when they change
+	SaveInterval int
+}
+
+// usageStore the usage counts of the bot, nil if they're not enabled
+var usageStore *UsageStore
+
+// loadUsageStore loads the usage counts from the file in the config
+func loadUsageStore() (*UsageStore, error) {
+	storePath, err := DefaultUsageFile()
+	if err != nil {
+		return nil, err
+	}
+
+	saltPath, err := DefaultUsageSaltFile()
+	if err != nil {
+		return nil, err
+	}
+	return LoadUsageStore(storePath, saltPath, LoadedConfig.UsageStats.Anonymize)
+}
+
+// recordUsage counts a generation by a user, users who have opted out aren't counted
+func recordUsage(userID string, modelID string, guildID string) {
+	if usageStore == nil || userID == "" {
+		return
+	}
+	if consentRegistry != nil && consentRegistry.IsOptedOut(userID) {
+		return
+	}
+	usageStore.Record(userID, modelID, guildID, time.Now())
+}
+
+// recordInteractionUsage counts a generation by the user of an interaction
+func recordInteractionUsage(i *discordgo.InteractionCreate, modelID string) {
+	if user := interactionUser(i); user != nil {
+		recordUsage(user.ID, modelID, i.GuildID)
+	}
+}
+
+// saveUsage saves the usage counts if they have changed
+func saveUsage() error {
+	if err := usageStore.Save(); err != nil {
+		logger.Error("Failed to save usage statistics", "error", err)
+		return fmt.Errorf("failed to save usage statistics: %v", err)
+	}
+	return nil
+}
+
+// runUsageStats saves the usage counts periodically until stop is closed
+func runUsageStats(stop <-chan struct{}) {
+	interval := LoadedConfig.UsageStats.SaveInterval
+	if interval <= 0 {
+		interval = 60
+	}
+
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			_ = saveUsage()
+		}
+	}
+}
+
+// isGlobalAdmin checks if the user of an interaction is in AdminUserIDs, they can see the usage of every guild
+func isGlobalAdmin(i *discordgo.InteractionCreate) bool {
+	user := interactionUser(i)
+	if user == nil {
+		return false
+	}
+
+	for _, userID := range LoadedConfig.AdminUserIDs {
+		if user.ID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// usageModelName returns the name of a model for the leaderboards, the ID if the model isn't found anymore
+func usageModelName(modelID string) string {
+	if model := getModel(modelID); model != nil && model.Info.ID == modelID {
+		return model.Info.Name
+	}
+	return modelID
+}
+
+// usageUserName returns how a user is shown in the leaderboards
+func usageUserName(userID string) string {
+	if strings.HasPrefix(userID, anonymousUserPrefix) {
+		return userID
+	}
+	return "<@" + userID + ">"
+}
+
+// formatLeaderboard formats the top counts as a line, name returns how each key is shown
+func formatLeaderboard(title string, counts map[string]int64, name func(key string) string) string {
+	top := TopUsage(counts, 5)
+	if len(top) == 0 {
+		return title + ": none\n"
+	}
+
+	entries := make([]string, 0, len(top))
+	for _, count := range top {
+		entries = append(entries, fmt.Sprintf("%s (%d)", name(count.Key), count.Count))
+	}
+	return title + ": " + strings.Join(entries, ", ") + "\n"
+}
+
+// formatTrend formats the change from the previous period as a percentage
+func formatTrend(current int64, previous int64) string {
+	if previous == 0 {
+		if current == 0 {
+			return "no change"
+		}
+		return "none the period before"
+	}
+	return fmt.Sprintf("%+.0f%% from the period before", float64(current-previous)/float64(previous)*100)
+}
+
+// usageStats returns the leaderboards & trends of the last days. Only the usage of guildID is shown if it's not empty
+func usageStats(days int, guildID string, now time.Time) string {
+	today := now.UTC().Truncate(24 * time.Hour)
+	until := today.AddDate(0, 0, 1)
+	from := until.AddDate(0, 0, -days)
+
+	totals := usageStore.Totals(from, until, guildID)
+	previous := usageStore.Totals(from.AddDate(0, 0, -days), from, guildID)
+
+	var stats strings.Builder
+	fmt.Fprintf(&stats, "Usage in the last %d days: %d generations, %s\n", days, totals.Total,
+		formatTrend(totals.Total, previous.Total))
+
+	stats.WriteString(formatLeaderboard("Top models", totals.Models, usageModelName))
+	stats.WriteString(formatLeaderboard("Top users", totals.Users, usageUserName))
+	if guildID == "" {
+		stats.WriteString(formatLeaderboard("Top guilds", totals.Guilds, func(key string) string {
+			if key == "" {
+				return "DMs"
+			}
+			return key
+		}))
+	}
+
+	// at most 2 weeks of daily counts fit nicely
+	shownDays := days
+	if shownDays > 14 {
+		shownDays = 14
+	}
+	dailyCounts := make([]string, 0, shownDays)
+	for day := today.AddDate(0, 0, 1-shownDays); day.After(today) == false; day = day.AddDate(0, 0, 1) {
+		dailyCounts = append(dailyCounts, fmt.Sprintf("%d", totals.Days[day.Format(usageDayFormat)]))
+	}
+	fmt.Fprintf(&stats, "Daily generations, oldest first: %s", strings.Join(dailyCounts, " "))
+
+	return stats.String()
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupUsageTest sets an empty usage store for testing
+func setupUsageTest(t *testing.T) {
+	testDir, err := os.MkdirTemp(os.TempDir(), "hurabotTestUsage")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := LoadUsageStore(path.Join(testDir, "usage.json"), path.Join(testDir, "usage.json.salt"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldStore := usageStore
+	usageStore = store
+
+	t.Cleanup(func() {
+		usageStore = oldStore
+		if err := os.RemoveAll(testDir); err != nil {
+			t.Logf("failed to remove the test directory %s: %v", testDir, err)
+		}
+	})
+}
+
+// statsInteraction makes an interaction of the stats subcommand
+func statsInteraction(options ...*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionCreate {
+	return commandInteraction("hurabot", &discordgo.ApplicationCommandInteractionDataOption{
+		Name:    "stats",
+		Type:    discordgo.ApplicationCommandOptionSubCommand,
+		Options: options,
+	})
+}
+
+func TestUsageStats(t *testing.T) {
+	setupBotTest(t, &MainBotConfig{MaxWords: 20, DefaultWords: 5, AdminUserIDs: []string{"30"}}, "hello")
+	setupUsageTest(t)
+
+	s := newFakeSession()
+	interactionHandler(s, commandInteraction("generate-text", stringOption("model", "test")))
+	interactionHandler(s, commandInteraction("generate-text", stringOption("model", "test")))
+	usageStore.Record("31", "test", "21", time.Now())
+
+	s = newFakeSession()
+	interactionHandler(s, statsInteraction(intOption("days", 3)))
+
+	if len(s.responses) != 1 {
+		t.Fatalf("expected a response, got %+v", s.responses)
+	}
+	stats := s.responses[0].Data.Content
+
+	for _, want := range []string{
+		"Usage in the last 3 days: 3 generations",
+		"Top models: Test (3)",
+		"Top users: <@30> (2), <@31> (1)",
+		"Top guilds: 20 (2), 21 (1)",
+		"Daily generations, oldest first: 0 0 3",
+	} {
+		if strings.Contains(stats, want) == false {
+			t.Errorf("stats are missing %q:\n%s", want, stats)
+		}
+	}
+
+	// admins of a guild only see the usage of the guild
+	LoadedConfig.AdminUserIDs = nil
+	LoadedConfig.GuildID = "20"
+	interaction := statsInteraction()
+	interaction.Member.Permissions = discordgo.PermissionAdministrator
+
+	s = newFakeSession()
+	interactionHandler(s, interaction)
+
+	stats = s.responses[0].Data.Content
+	if strings.Contains(stats, "Top users: <@30> (2)\n") == false || strings.Contains(stats, "Top guilds") {
+		t.Errorf("expected only the usage of guild 20, got:\n%s", stats)
+	}
+
+	interaction = statsInteraction(intOption("days", 0))
+	interaction.Member.Permissions = discordgo.PermissionAdministrator
+
+	s = newFakeSession()
+	interactionHandler(s, interaction)
+
+	if s.responses[0].Data.Content != "Days has to be between 1 and 365" {
+		t.Errorf("expected invalid days to be refused, got %+v", s.responses[0].Data.Content)
+	}
+}
+
+func TestUsageOptedOut(t *testing.T) {
+	setupBotTest(t, &MainBotConfig{}, "hello")
+	setupUsageTest(t)
+
+	testDir, err := os.MkdirTemp(os.TempDir(), "hurabotTestUsageOptedOut")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry, err := LoadConsentRegistry(path.Join(testDir, "consent.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldRegistry := consentRegistry
+	consentRegistry = registry
+	defer func() {
+		consentRegistry = oldRegistry
+		if err := os.RemoveAll(testDir); err != nil {
+			t.Logf("failed to remove the test directory %s: %v", testDir, err)
+		}
+	}()
+
+	recordUsage("30", "test", "20")
+
+	s := newFakeSession()
+	interactionHandler(s, commandInteraction("hurabot", &discordgo.ApplicationCommandInteractionDataOption{
+		Name: "optout",
+		Type: discordgo.ApplicationCommandOptionSubCommand,
+	}))
+	recordUsage("30", "test", "20")
+
+	if totals := usageStore.Totals(time.Now().AddDate(0, 0, -1), time.Now().AddDate(0, 0, 1), ""); totals.Total != 0 {
+		t.Errorf("expected the usage of an opted out user to be forgotten, got %+v", totals)
+	}
+}
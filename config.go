@@ -4,7 +4,7 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
-	"log"
+	"io"
 	"os"
 	"path"
 	"strings"
@@ -22,17 +22,64 @@ type MainBotConfig struct {
 	ModelsToUse []string
 	// Maximum amount of words that can be generated with the Discord bot
 	MaxWords int
+	// Amount of words generated if the amount isn't given
+	DefaultWords int
+	// ID of the model used by Reply as model, the model is chosen when replying if empty
+	DefaultModel string
+	// Settings for each guild by guild ID, the guilds' commands are registered to each of them
+	Guilds map[string]GuildConfig
+	// Memory in megabytes the bot can use for keeping models loaded, 0 keeps every used model loaded
+	ModelCacheSize int
+	// How often in seconds to check the model files for changes while the bot runs, 0 disables checking
+	ModelReloadInterval int
+	// Who can use each model, by model ID. Models not listed can be used by everyone
+	ModelAccess map[string]ModelAccess
+	// Rate limit of commands for each user
+	UserRateLimit RateLimit
+	// Rate limit of commands for each channel
+	ChannelRateLimit RateLimit
+	// Rate limit of commands for each guild
+	GuildRateLimit RateLimit
+	// File where the users who have opted out of the models are saved
+	ConsentFile string
+	// Settings for replying to messages without commands
+	Passive PassiveConfig
+	// Settings for the model that learns from new messages
+	LiveModel LiveModelConfig
+	// Settings for counting who generates text with which models
+	UsageStats UsageStatsConfig
+	// Messages posted automatically on a schedule
+	Schedules []ScheduleConfig
+	// File where the times of the scheduled posts are saved so they aren't posted twice
+	ScheduleStateFile string
+	// IDs of the users that can use the admin commands
+	AdminUserIDs []string
+	// IDs of the roles that can use the admin commands
+	AdminRoleIDs []string
 	// Logging directory
 	LogDir string
-	// Logging level
+	// Logging level: debug, info, warn or error
 	LogLevel string
+	// Format of the log lines: text or json
+	LogFormat string
+	// Keep a .bak copy of the previous version when overwriting models & configs
+	KeepBackups bool
+	// Seconds to wait for running commands to finish when the bot shuts down
+	ShutdownTimeout int
+	// Update the commands on start only if they changed & leave them registered on exit
+	PersistentCommands bool
+	// Address where the Prometheus metrics are served, not served if empty
+	MetricsListenAddress string
+	// Address where the /healthz & /readyz health checks are served, not served if empty
+	HealthListenAddress string
 }
 
 func (config MainBotConfig) createNewConfig() MainBotConfig {
 	ed, err := os.Executable()
 
 	if err != nil {
-		log.Fatalln(err)
+		logger.Error("Failed to find the executable directory", "error", err)
+		os.Exit(1)
 	}
 
 	config.AuthenticationToken = ""
@@ -40,8 +87,38 @@ func (config MainBotConfig) createNewConfig() MainBotConfig {
 	config.ModelDirectory = path.Join(path.Dir(ed), "models")
 	config.ModelsToUse = make([]string, 0)
 	config.MaxWords = 200
+	config.DefaultWords = 50
+	config.Guilds = make(map[string]GuildConfig)
+	config.ModelReloadInterval = 60
+	config.ModelAccess = make(map[string]ModelAccess)
+	config.UserRateLimit = RateLimit{Commands: 5, Seconds: 60}
+	config.ChannelRateLimit = RateLimit{Commands: 20, Seconds: 60}
+	config.ConsentFile = path.Join(path.Dir(ed), "consent.json")
+	config.Passive = PassiveConfig{
+		ChannelIDs:      make([]string, 0),
+		ReplyToMentions: true,
+		Keywords:        make([]string, 0),
+		Cooldown:        30,
+	}
+	config.LiveModel = LiveModelConfig{
+		ChannelIDs:   make([]string, 0),
+		ID:           "live",
+		Name:         "Live",
+		SaveInterval: 300,
+	}
+	config.UsageStats = UsageStatsConfig{
+		File:         path.Join(path.Dir(ed), "usage.json"),
+		SaveInterval: 60,
+	}
+	config.Schedules = make([]ScheduleConfig, 0)
+	config.ScheduleStateFile = path.Join(path.Dir(ed), "schedule_state.json")
+	config.AdminUserIDs = make([]string, 0)
+	config.AdminRoleIDs = make([]string, 0)
 	config.LogDir = path.Join(path.Dir(ed), "logs")
-	config.LogLevel = "default"
+	config.LogLevel = "info"
+	config.LogFormat = "text"
+	config.KeepBackups = true
+	config.ShutdownTimeout = 10
 
 	return config
 }
@@ -49,6 +126,9 @@ func (config MainBotConfig) createNewConfig() MainBotConfig {
 // LoadedConfig the current loaded configuration
 var LoadedConfig *MainBotConfig
 
+// LoadedConfigPath path of the file LoadedConfig was loaded from
+var LoadedConfigPath string
+
 // ConfigLoadConfig loads the MainBotConfig from an os.File
 func ConfigLoadConfig(configFile *os.File) error {
 
@@ -76,6 +156,8 @@ func ConfigLoadConfig(configFile *os.File) error {
 		return fmt.Errorf("failed to decode config file: %v", err)
 	}
 
+	LoadedConfigPath = configFile.Name()
+
 	return nil
 }
 
@@ -99,10 +181,84 @@ func ConfigShowConfig(configFile *os.File) error {
 	for i := range LoadedConfig.ModelsToUse {
 		fmt.Println(LoadedConfig.ModelsToUse[i])
 	}
+	fmt.Printf("Guild settings: (%d total)\n", len(LoadedConfig.Guilds))
+
+	for guildID, guild := range LoadedConfig.Guilds {
+		fmt.Printf("%s: models [%s], maximum words %d, default words %d, default model %s, allowed channels [%s], admin roles [%s]\n",
+			guildID, strings.Join(guild.ModelsToUse, ", "), guild.MaxWords, guild.DefaultWords, guild.DefaultModel,
+			strings.Join(guild.AllowedChannelIDs, ", "), strings.Join(guild.AdminRoleIDs, ", "))
+	}
+	fmt.Printf("Passive replies enabled: %t\n"+
+		"Passive reply channels: %s\n"+
+		"Passive reply model: %s\n"+
+		"Passive reply words: %d\n"+
+		"Reply to mentions: %t\n"+
+		"Reply keywords: %s\n"+
+		"Reply probability: %g\n"+
+		"Reply cooldown: %d seconds\n",
+		LoadedConfig.Passive.Enabled, strings.Join(LoadedConfig.Passive.ChannelIDs, ", "), LoadedConfig.Passive.Model,
+		LoadedConfig.Passive.Words, LoadedConfig.Passive.ReplyToMentions, strings.Join(LoadedConfig.Passive.Keywords, ", "),
+		LoadedConfig.Passive.Probability, LoadedConfig.Passive.Cooldown)
+	fmt.Printf("Live model enabled: %t\n"+
+		"Live model channels: %s\n"+
+		"Live model ID: %s\n"+
+		"Live model name: %s\n"+
+		"Live model description: %s\n"+
+		"Live model file: %s\n"+
+		"Live model save interval: %d seconds\n",
+		LoadedConfig.LiveModel.Enabled, strings.Join(LoadedConfig.LiveModel.ChannelIDs, ", "), LoadedConfig.LiveModel.ID,
+		LoadedConfig.LiveModel.Name, LoadedConfig.LiveModel.Description, LoadedConfig.LiveModel.File,
+		LoadedConfig.LiveModel.SaveInterval)
+	fmt.Printf("Usage statistics enabled: %t\n"+
+		"Usage statistics file: %s\n"+
+		"Usage statistics anonymized: %t\n"+
+		"Usage statistics salt file: %s\n"+
+		"Usage statistics save interval: %d seconds\n",
+		LoadedConfig.UsageStats.Enabled, LoadedConfig.UsageStats.File, LoadedConfig.UsageStats.Anonymize,
+		LoadedConfig.UsageStats.SaltFile, LoadedConfig.UsageStats.SaveInterval)
+	fmt.Printf("Schedules: (%d total)\n", len(LoadedConfig.Schedules))
+
+	for _, schedule := range LoadedConfig.Schedules {
+		fmt.Printf("%s: %d words with model %s to channel %s at \"%s\" %s\n", schedule.ID, schedule.Words,
+			schedule.Model, schedule.ChannelID, schedule.Schedule, schedule.Timezone)
+	}
+	fmt.Printf("Schedule state file: %s\n", LoadedConfig.ScheduleStateFile)
+	fmt.Printf("Model access rules: (%d total)\n", len(LoadedConfig.ModelAccess))
+
+	for modelID, access := range LoadedConfig.ModelAccess {
+		fmt.Printf("%s: allowed guilds [%s], channels [%s], roles [%s], users [%s]; "+
+			"denied guilds [%s], channels [%s], roles [%s], users [%s]\n", modelID,
+			strings.Join(access.AllowedGuildIDs, ", "), strings.Join(access.AllowedChannelIDs, ", "),
+			strings.Join(access.AllowedRoleIDs, ", "), strings.Join(access.AllowedUserIDs, ", "),
+			strings.Join(access.DeniedGuildIDs, ", "), strings.Join(access.DeniedChannelIDs, ", "),
+			strings.Join(access.DeniedRoleIDs, ", "), strings.Join(access.DeniedUserIDs, ", "))
+	}
 	fmt.Printf("Maximum words: %d\n"+
+		"Default words: %d\n"+
+		"Default model: %s\n"+
+		"Model cache size: %d MB\n"+
+		"Model reload interval: %d seconds\n"+
+		"User rate limit: %d commands per %d seconds\n"+
+		"Channel rate limit: %d commands per %d seconds\n"+
+		"Guild rate limit: %d commands per %d seconds\n"+
+		"Consent file: %s\n"+
+		"Admin user IDs: %s\n"+
+		"Admin role IDs: %s\n"+
 		"Log directory: %s\n"+
-		"Logging level: %s\n",
-		LoadedConfig.MaxWords, LoadedConfig.LogDir, LoadedConfig.LogLevel)
+		"Logging level: %s\n"+
+		"Logging format: %s\n"+
+		"Keep backups: %t\n"+
+		"Shutdown timeout: %d seconds\n"+
+		"Persistent commands: %t\n"+
+		"Metrics listen address: %s\n"+
+		"Health check listen address: %s\n",
+		LoadedConfig.MaxWords, LoadedConfig.DefaultWords, LoadedConfig.DefaultModel, LoadedConfig.ModelCacheSize, LoadedConfig.ModelReloadInterval,
+		LoadedConfig.UserRateLimit.Commands, LoadedConfig.UserRateLimit.Seconds,
+		LoadedConfig.ChannelRateLimit.Commands, LoadedConfig.ChannelRateLimit.Seconds,
+		LoadedConfig.GuildRateLimit.Commands, LoadedConfig.GuildRateLimit.Seconds,
+		LoadedConfig.ConsentFile, strings.Join(LoadedConfig.AdminUserIDs, ", "), strings.Join(LoadedConfig.AdminRoleIDs, ", "), LoadedConfig.LogDir, LoadedConfig.LogLevel,
+		LoadedConfig.LogFormat, LoadedConfig.KeepBackups, LoadedConfig.ShutdownTimeout, LoadedConfig.PersistentCommands,
+		LoadedConfig.MetricsListenAddress, LoadedConfig.HealthListenAddress)
 
 	return nil
 }
@@ -129,11 +285,7 @@ func ConfigCreateEmptyConfig(configFile *os.File) error {
 
 	if !os.IsNotExist(err) && err != nil {
 		return fmt.Errorf("failed to stat config file %s: %v", configFile.Name(), err)
-	} else if os.IsNotExist(err) {
-		if err := configFile.Truncate(0); err != nil {
-			fmt.Printf("Failed to truncate config file %s: %v\n", configFile.Name(), err)
-		}
-	} else if configFileInfo.Size() != 0 {
+	} else if err == nil && configFileInfo.Size() != 0 {
 		reader := bufio.NewReader(os.Stdin)
 		fmt.Printf("Config file %s already exists, overwrite? (y/n)", configFile.Name())
 		resultChar, _, err := reader.ReadRune()
@@ -144,9 +296,6 @@ func ConfigCreateEmptyConfig(configFile *os.File) error {
 
 		switch strings.ToLower(string(resultChar)) {
 		case "y":
-			if err := configFile.Truncate(0); err != nil {
-				fmt.Printf("Failed to truncate config file %s: %v\n", configFile.Name(), err)
-			}
 		case "n":
 			return fmt.Errorf("aborted by user")
 		default:
@@ -154,50 +303,69 @@ func ConfigCreateEmptyConfig(configFile *os.File) error {
 		}
 	}
 
-	enc := json.NewEncoder(configFile)
-	enc.SetIndent("", "\t")
-	if err := enc.Encode(botConfig); err != nil {
-		return fmt.Errorf("failed to write config to %s: %v", configFile.Name(), err.Error())
-	}
-
-	fmt.Println("Wrote a new config to " + configFile.Name())
-
 	if err := configFile.Close(); err != nil {
 		fmt.Printf("Failed to close config file %s: %v\n", configFile.Name(), err)
 	}
 
+	if err := ConfigWriteConfig(&botConfig, configFile.Name()); err != nil {
+		return err
+	}
+
+	fmt.Println("Wrote a new config to " + configFile.Name())
+
 	return nil
 }
 
-func ConfigEdit(configFile *os.File) {
-
-	ConfigEditCUI(configFile)
+// ConfigWriteConfig writes a MainBotConfig to a file, replacing the file only after the config is written completely
+func ConfigWriteConfig(config *MainBotConfig, configPath string) error {
+	return WriteFileAtomic(configPath, 0660, config.KeepBackups, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		if err := enc.Encode(config); err != nil {
+			return fmt.Errorf("failed to write config to %s: %v", configPath, err)
+		}
+		return nil
+	})
+}
 
-	if ConfigNoSave == true {
-		fmt.Println("Config editing cancelled")
-		return
+// ConfigUpdateFile changes the config file LoadedConfig was loaded from. The file is read again
+// so only the changes made by update are saved, not the changes made to LoadedConfig while the bot runs
+func ConfigUpdateFile(update func(config *MainBotConfig)) error {
+	if LoadedConfigPath == "" {
+		return fmt.Errorf("no config file was loaded")
 	}
 
-	botConfigJson, err := json.MarshalIndent(LoadedConfig, "", "\t")
-
+	configFileContent, err := os.ReadFile(LoadedConfigPath)
 	if err != nil {
-		log.Fatalln(err)
+		return fmt.Errorf("failed to read config file: %v", err)
 	}
 
-	err = configFile.Truncate(0)
-
-	if err != nil {
-		log.Fatalln(err)
+	var config MainBotConfig
+	if err := json.Unmarshal(configFileContent, &config); err != nil {
+		return fmt.Errorf("failed to decode config file: %v", err)
 	}
 
-	_, err = configFile.Write(botConfigJson)
+	update(&config)
 
-	if err != nil {
-		log.Fatalln("Failed to write new config to " + configFile.Name() + ": " + err.Error())
+	return ConfigWriteConfig(&config, LoadedConfigPath)
+}
+
+func ConfigEdit(configFile *os.File) {
+
+	ConfigEditCUI(configFile)
+
+	if ConfigNoSave == true {
+		fmt.Println("Config editing cancelled")
+		return
 	}
 
 	if err := configFile.Close(); err != nil {
-		log.Printf("Failed to close config file %s: %v", configFile.Name(), err)
+		logger.Warn("Failed to close config file", "file", configFile.Name(), "error", err)
+	}
+
+	if err := ConfigWriteConfig(LoadedConfig, configFile.Name()); err != nil {
+		logger.Error("Failed to write new config", "file", configFile.Name(), "error", err)
+		os.Exit(1)
 	}
 
 	fmt.Println("Edited config at " + configFile.Name())
@@ -108,9 +108,10 @@ func drawOptions(v *gocui.View) {
 		"Maximum amount of words: %d\n"+
 		"Log directory: %s\n"+
 		"Log level: %s\n"+
+		"Keep backups: %t\n"+
 		"Save config",
 		LoadedConfig.AuthenticationToken, LoadedConfig.GuildID, LoadedConfig.ModelDirectory, len(LoadedConfig.ModelsToUse),
-		LoadedConfig.MaxWords, LoadedConfig.LogDir, LoadedConfig.LogLevel)
+		LoadedConfig.MaxWords, LoadedConfig.LogDir, LoadedConfig.LogLevel, LoadedConfig.KeepBackups)
 }
 
 // Print the models to use to a gocui.View
@@ -158,7 +159,7 @@ func configEditLogLevelCursorDown(_ *gocui.Gui, v *gocui.View) error {
 		cx, cy := v.Cursor()
 		if err := v.SetCursor(cx, cy+1); err != nil {
 			ox, oy := v.Origin()
-			if ((oy + cy) + 1) < 2 {
+			if ((oy + cy) + 1) < 4 {
 				if err := v.SetOrigin(ox, oy+1); err != nil {
 					return err
 				}
@@ -325,7 +326,7 @@ func configEditEnterHandler(g *gocui.Gui, v *gocui.View) error {
 			}
 		// open log level edit view
 		case 6:
-			if v, err := g.SetView("editLogLevel", maxX/2-30, maxY/2, maxX/2+30, maxY/2+3); err != nil {
+			if v, err := g.SetView("editLogLevel", maxX/2-30, maxY/2, maxX/2+30, maxY/2+5); err != nil {
 				if err != gocui.ErrUnknownView {
 					return err
 				}
@@ -335,8 +336,10 @@ func configEditEnterHandler(g *gocui.Gui, v *gocui.View) error {
 				v.SelBgColor = gocui.ColorCyan
 				v.SelFgColor = gocui.ColorBlack
 
-				fmt.Fprintln(v, "default")
-				fmt.Fprintln(v, "verbose")
+				fmt.Fprintln(v, "debug")
+				fmt.Fprintln(v, "info")
+				fmt.Fprintln(v, "warn")
+				fmt.Fprintln(v, "error")
 
 				if v, err := g.SetView("helpBar", int(float32(maxX)*0.05), int(float32(maxY)*0.85), int(float32(maxX)*0.95), int(float32(maxY)*0.90)); err != nil {
 					if err != gocui.ErrUnknownView {
@@ -349,8 +352,12 @@ func configEditEnterHandler(g *gocui.Gui, v *gocui.View) error {
 					return err
 				}
 			}
-		// quit the CUI & save
+		// toggle keeping backups
 		case 7:
+			LoadedConfig.KeepBackups = !LoadedConfig.KeepBackups
+			drawOptions(v)
+		// quit the CUI & save
+		case 8:
 			return gocui.ErrQuit
 		}
 		return nil
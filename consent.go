@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ConsentRegistry the users who have asked to be left out of the models
+type ConsentRegistry struct {
+	// Time each user opted out, by user ID
+	OptedOut map[string]time.Time
+
+	// path of the registry file
+	filePath string
+	// lock for reading & changing the registry at the same time
+	mutex sync.RWMutex
+}
+
+// LoadConsentRegistry loads a ConsentRegistry from a file, a missing file is an empty registry
+func LoadConsentRegistry(registryPath string) (*ConsentRegistry, error) {
+	registry := &ConsentRegistry{OptedOut: make(map[string]time.Time), filePath: registryPath}
+
+	registryContents, err := os.ReadFile(registryPath)
+	if os.IsNotExist(err) {
+		return registry, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read consent file %s: %v", registryPath, err)
+	}
+
+	if err := json.Unmarshal(registryContents, registry); err != nil {
+		return nil, fmt.Errorf("failed to decode consent file %s: %v", registryPath, err)
+	}
+
+	if registry.OptedOut == nil {
+		registry.OptedOut = make(map[string]time.Time)
+	}
+
+	return registry, nil
+}
+
+// IsOptedOut checks if a user has opted out
+func (registry *ConsentRegistry) IsOptedOut(userID string) bool {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	_, ok := registry.OptedOut[userID]
+	return ok
+}
+
+// OptedOutContributors returns the contributors who have opted out
+func (registry *ConsentRegistry) OptedOutContributors(contributors []string) []string {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	optedOut := make([]string, 0)
+	for _, userID := range contributors {
+		if _, ok := registry.OptedOut[userID]; ok {
+			optedOut = append(optedOut, userID)
+		}
+	}
+	return optedOut
+}
+
+// OptOut adds a user to the registry & saves it. Returns false if the user had already opted out
+func (registry *ConsentRegistry) OptOut(userID string) (bool, error) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if _, ok := registry.OptedOut[userID]; ok {
+		return false, nil
+	}
+
+	registry.OptedOut[userID] = time.Now().UTC()
+
+	if err := registry.save(); err != nil {
+		delete(registry.OptedOut, userID)
+		return false, err
+	}
+	return true, nil
+}
+
+// save writes the registry to its file, the mutex has to be locked
+func (registry *ConsentRegistry) save() error {
+	return WriteFileAtomic(registry.filePath, 0660, keepBackups(), func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		if err := enc.Encode(registry); err != nil {
+			return fmt.Errorf("failed to write consent file %s: %v", registry.filePath, err)
+		}
+		return nil
+	})
+}
+
+// DefaultConsentFile returns the consent file from the config, or consent.json next to the executable
+func DefaultConsentFile() (string, error) {
+	if LoadedConfig != nil && LoadedConfig.ConsentFile != "" {
+		return LoadedConfig.ConsentFile, nil
+	}
+
+	ed, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the executable directory for the consent file: %v", err)
+	}
+	return path.Join(path.Dir(ed), "consent.json"), nil
+}
+
+// RemoveOptedOutMessages leaves out the messages of the users who have opted out
+func RemoveOptedOutMessages(messages []MessagesCsv, registry *ConsentRegistry) []MessagesCsv {
+	keptMessages := make([]MessagesCsv, 0, len(messages))
+
+	for _, message := range messages {
+		if message.AuthorID != "" && registry.IsOptedOut(message.AuthorID) {
+			continue
+		}
+		keptMessages = append(keptMessages, message)
+	}
+	return keptMessages
+}
+
+// modelContributors returns the sorted IDs of the authors of the messages, messages without an author are skipped
+func modelContributors(messages []ModelMessage) []string {
+	authors := make(map[string]bool)
+	for _, message := range messages {
+		if message.AuthorID != "" {
+			authors[message.AuthorID] = true
+		}
+	}
+
+	contributors := make([]string, 0, len(authors))
+	for authorID := range authors {
+		contributors = append(contributors, authorID)
+	}
+	sort.Strings(contributors)
+
+	return contributors
+}
+
+// ExportAuthorID reads the ID of the user who made a Discord data export from the account/user.json file
+// next to the messages directory, empty if it's not found
+func ExportAuthorID(directory *os.File) string {
+	userContents, err := os.ReadFile(path.Join(path.Dir(path.Clean(directory.Name())), "account", "user.json"))
+	if err != nil {
+		return ""
+	}
+
+	var user struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(userContents, &user); err != nil {
+		logger.Warn("Failed to decode the user of the data export", "error", err)
+		return ""
+	}
+	return user.ID
+}
+
+// ApplyMessageAuthors sets the author of the messages to ModelAuthorID or the user who made the data export,
+// and leaves out the messages of the users who have opted out
+func ApplyMessageAuthors(directory *os.File, messages []MessagesCsv) ([]MessagesCsv, error) {
+	authorID := ModelAuthorID
+	if authorID == "" {
+		authorID = ExportAuthorID(directory)
+	}
+
+	if authorID == "" {
+		logger.Warn("Author of the messages is not known, opted out users can't be skipped")
+		return messages, nil
+	}
+
+	for i := range messages {
+		messages[i].AuthorID = authorID
+	}
+
+	consentFile, err := DefaultConsentFile()
+	if err != nil {
+		return nil, err
+	}
+
+	registry, err := LoadConsentRegistry(consentFile)
+	if err != nil {
+		return nil, err
+	}
+
+	keptMessages := RemoveOptedOutMessages(messages, registry)
+	if len(keptMessages) < len(messages) {
+		logger.Info("Skipped messages from users who have opted out", "messages", len(messages)-len(keptMessages))
+	}
+
+	return keptMessages, nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestConsentRegistry(t *testing.T) {
+	testDir, err := os.MkdirTemp(os.TempDir(), "hurabotTestConsentRegistry")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registryPath := path.Join(testDir, "consent.json")
+
+	// a missing file is an empty registry
+	registry, err := LoadConsentRegistry(registryPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if registry.IsOptedOut("123") {
+		t.Error("user was opted out in an empty registry")
+	}
+
+	optedOut, err := registry.OptOut("123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if optedOut == false {
+		t.Error("opting out returned false for a new user")
+	}
+
+	if optedOut, _ := registry.OptOut("123"); optedOut == true {
+		t.Error("opting out returned true for a user that had already opted out")
+	}
+
+	// the opt out should have been saved
+	registry, err = LoadConsentRegistry(registryPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if registry.IsOptedOut("123") == false {
+		t.Error("opt out was not saved")
+	}
+
+	if optedOut := registry.OptedOutContributors([]string{"123", "456"}); len(optedOut) != 1 || optedOut[0] != "123" {
+		t.Errorf("expected only 123 to be opted out, got %v", optedOut)
+	}
+
+	messages := []MessagesCsv{
+		{ID: 1, Contents: "mine", AuthorID: "456"},
+		{ID: 2, Contents: "opted out", AuthorID: "123"},
+		{ID: 3, Contents: "unknown author"},
+	}
+
+	keptMessages := RemoveOptedOutMessages(messages, registry)
+	if len(keptMessages) != 2 || keptMessages[0].ID != 1 || keptMessages[1].ID != 3 {
+		t.Errorf("expected messages 1 & 3 to be kept, got %v", keptMessages)
+	}
+
+	if err := os.RemoveAll(testDir); err != nil {
+		t.Logf("failed to remove the test directory %s: %v", testDir, err)
+	}
+}
+
+func TestModelContributors(t *testing.T) {
+	wordModel := &WordModel{
+		Channels: []int{1},
+		Messages: []ModelMessage{
+			{ID: 1, AuthorID: "456", Words: []string{"hello"}},
+			{ID: 2, AuthorID: "123", Words: []string{"world"}},
+			{ID: 3, AuthorID: "456", Words: []string{"again"}},
+			{ID: 4, Words: []string{"unknown"}},
+		},
+	}
+	wordModel.RebuildWords()
+
+	if len(wordModel.Contributors) != 2 || wordModel.Contributors[0] != "123" || wordModel.Contributors[1] != "456" {
+		t.Errorf("expected contributors [123 456], got %v", wordModel.Contributors)
+	}
+}
+
+func TestExportAuthorID(t *testing.T) {
+	testDir, err := os.MkdirTemp(os.TempDir(), "hurabotTestExportAuthorID")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(path.Join(testDir, "messages"), 0770); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(path.Join(testDir, "account"), 0770); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path.Join(testDir, "account", "user.json"), []byte(`{"id": "123", "username": "test"}`), 0660); err != nil {
+		t.Fatal(err)
+	}
+
+	messagesDir, err := os.Open(path.Join(testDir, "messages"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if authorID := ExportAuthorID(messagesDir); authorID != "123" {
+		t.Errorf("expected author ID 123, got %s", authorID)
+	}
+
+	if err := messagesDir.Close(); err != nil {
+		t.Logf("failed to close test directory %s: %v", messagesDir.Name(), err)
+	}
+
+	if err := os.RemoveAll(testDir); err != nil {
+		t.Logf("failed to remove the test directory %s: %v", testDir, err)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule a parsed cron expression with the fields minute, hour, day of month, month & day of week
+type CronSchedule struct {
+	// allowed values of each field as bits
+	minutes  uint64
+	hours    uint64
+	days     uint64
+	months   uint64
+	weekdays uint64
+	// if day of month or day of week is *, both have to match only if neither is *
+	anyDay     bool
+	anyWeekday bool
+}
+
+// cronField the range of values of a cron field
+type cronField struct {
+	name string
+	min  int
+	max  int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	// 7 is also Sunday
+	{"day of week", 0, 7},
+}
+
+// ParseCron parses a cron expression like "30 8 * * 1-5". Fields can be *, numbers, ranges like 1-5,
+// lists like 1,3,5 and steps like */15 or 0-30/10
+func ParseCron(expression string) (*CronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression %q has %d fields instead of %d", expression, len(fields), len(cronFields))
+	}
+
+	values := make([]uint64, len(fields))
+	for i, field := range fields {
+		bits, err := parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %v", expression, err)
+		}
+		values[i] = bits
+	}
+
+	// Sunday can be 0 or 7
+	if values[4]&(1<<7) != 0 {
+		values[4] |= 1
+	}
+
+	return &CronSchedule{
+		minutes:    values[0],
+		hours:      values[1],
+		days:       values[2],
+		months:     values[3],
+		weekdays:   values[4],
+		anyDay:     fields[2] == "*",
+		anyWeekday: fields[4] == "*",
+	}, nil
+}
+
+// parseCronField parses a single comma separated cron field to bits
+func parseCronField(field string, info cronField) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q in %s", stepPart, info.name)
+			}
+		}
+
+		start, end := info.min, info.max
+		if rangePart != "*" {
+			startPart, endPart, isRange := strings.Cut(rangePart, "-")
+
+			var err error
+			start, err = strconv.Atoi(startPart)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q in %s", startPart, info.name)
+			}
+
+			end = start
+			if isRange {
+				end, err = strconv.Atoi(endPart)
+				if err != nil {
+					return 0, fmt.Errorf("invalid value %q in %s", endPart, info.name)
+				}
+			} else if hasStep {
+				// 5/15 means from 5 to the end every 15
+				end = info.max
+			}
+		}
+
+		if start < info.min || end > info.max || start > end {
+			return 0, fmt.Errorf("%s range %d-%d is not within %d-%d", info.name, start, end, info.min, info.max)
+		}
+
+		for value := start; value <= end; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+
+	return bits, nil
+}
+
+// dayMatches checks if the day of month & day of week of a time match the schedule
+func (schedule *CronSchedule) dayMatches(t time.Time) bool {
+	dayMatch := schedule.days&(1<<uint(t.Day())) != 0
+	weekdayMatch := schedule.weekdays&(1<<uint(t.Weekday())) != 0
+
+	switch {
+	case schedule.anyDay && schedule.anyWeekday:
+		return true
+	case schedule.anyDay:
+		return weekdayMatch
+	case schedule.anyWeekday:
+		return dayMatch
+	default:
+		return dayMatch || weekdayMatch
+	}
+}
+
+// Next returns the first time after t that matches the schedule, in the location of t.
+// Returns the zero time if nothing matches within five years, for example on February 30th
+func (schedule *CronSchedule) Next(t time.Time) time.Time {
+	location := t.Location()
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	limit := next.AddDate(5, 0, 0)
+
+	for next.Before(limit) {
+		if schedule.months&(1<<uint(next.Month())) == 0 {
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, location)
+			continue
+		}
+
+		if schedule.dayMatches(next) == false {
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, location)
+			continue
+		}
+
+		if schedule.hours&(1<<uint(next.Hour())) == 0 {
+			next = time.Date(next.Year(), next.Month(), next.Day(), next.Hour()+1, 0, 0, 0, location)
+			continue
+		}
+
+		if schedule.minutes&(1<<uint(next.Minute())) == 0 {
+			next = next.Truncate(time.Minute).Add(time.Minute)
+			continue
+		}
+
+		return next
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	invalid := []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+	}
+
+	for _, expression := range invalid {
+		if _, err := ParseCron(expression); err == nil {
+			t.Errorf("invalid expression %q was parsed", expression)
+		}
+	}
+
+	valid := []string{
+		"* * * * *",
+		"30 8 * * 1-5",
+		"*/15 0-6,18-23 1,15 */2 0,7",
+		"5/20 * * * *",
+	}
+
+	for _, expression := range valid {
+		if _, err := ParseCron(expression); err != nil {
+			t.Errorf("valid expression %q was not parsed: %v", expression, err)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	helsinki, err := time.LoadLocation("Europe/Helsinki")
+	if err != nil {
+		t.Skipf("timezone data not available: %v", err)
+	}
+
+	// Saturday
+	start := time.Date(2022, 3, 26, 12, 34, 56, 0, time.UTC)
+
+	tests := []struct {
+		expression string
+		after      time.Time
+		next       time.Time
+	}{
+		{"* * * * *", start, time.Date(2022, 3, 26, 12, 35, 0, 0, time.UTC)},
+		{"*/15 * * * *", start, time.Date(2022, 3, 26, 12, 45, 0, 0, time.UTC)},
+		{"5/20 * * * *", start, time.Date(2022, 3, 26, 12, 45, 0, 0, time.UTC)},
+		{"0 9 * * *", start, time.Date(2022, 3, 27, 9, 0, 0, 0, time.UTC)},
+		// next weekday is Monday
+		{"30 8 * * 1-5", start, time.Date(2022, 3, 28, 8, 30, 0, 0, time.UTC)},
+		// Sunday as 7
+		{"0 0 * * 7", start, time.Date(2022, 3, 27, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", start, time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)},
+		// day of month or day of week when both are set
+		{"0 0 1 * 0", start, time.Date(2022, 3, 27, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", start, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", start, time.Time{}},
+		// an exact match is not returned again
+		{"0 9 * * *", time.Date(2022, 3, 27, 9, 0, 0, 0, time.UTC), time.Date(2022, 3, 28, 9, 0, 0, 0, time.UTC)},
+		// times are in the location given, the clocks went forward on the 27th in Helsinki
+		{"0 9 * * *", start.In(helsinki), time.Date(2022, 3, 27, 9, 0, 0, 0, helsinki)},
+	}
+
+	for _, test := range tests {
+		schedule, err := ParseCron(test.expression)
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", test.expression, err)
+		}
+
+		if next := schedule.Next(test.after); next.Equal(test.next) == false {
+			t.Errorf("%q after %v: expected %v, got %v", test.expression, test.after, test.next, next)
+		}
+	}
+}
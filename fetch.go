@@ -0,0 +1,358 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the most messages Discord returns at once
+const fetchPageSize = 100
+
+// FetchOptions settings for fetching the messages of a channel from the Discord API
+type FetchOptions struct {
+	// ID of the channel to fetch
+	ChannelID string
+	// Only keep the messages of this user, every user if empty
+	AuthorID string
+	// Stop after this many messages are kept, no limit if 0
+	Limit int
+	// File where the progress is saved so an interrupted fetch can be resumed, nothing is saved if empty
+	CheckpointFile string
+	// Users whose messages are skipped, nil to keep everyone
+	Consent *ConsentRegistry
+	// Called after every page with the amount of messages kept so far
+	Progress func(kept int)
+	// Called when Discord rate limits the fetch, the request is retried after the wait
+	RateLimited func(wait time.Duration)
+	// Stops the fetch between pages when cancelled, the fetch can be continued from the checkpoint. Never stops if nil
+	Context context.Context
+}
+
+// fetchCheckpoint progress of an unfinished fetch. It's saved as a JSON header with the channel & author,
+// followed by a JSON record of every page so saving a page doesn't write the earlier pages again
+type fetchCheckpoint struct {
+	// ID of the channel fetched
+	ChannelID string
+	// ID of the user whose messages are kept
+	AuthorID string
+	// ID of the oldest message fetched, the fetch continues from the messages before it
+	Before string `json:"-"`
+	// Messages kept so far
+	Messages []ModelMessage `json:"-"`
+
+	// file the pages are added to, nil if the progress isn't saved
+	file *os.File
+}
+
+// fetchCheckpointPage a page of messages saved to the checkpoint file
+type fetchCheckpointPage struct {
+	// ID of the oldest message of the page
+	Before string
+	// Messages kept from the page
+	Messages []ModelMessage
+}
+
+// loadFetchCheckpoint loads the checkpoint of an earlier fetch & opens its file for adding pages, the file is made
+// if it doesn't exist. The checkpoint has to be closed when done
+func loadFetchCheckpoint(options FetchOptions) (*fetchCheckpoint, error) {
+	checkpoint := &fetchCheckpoint{ChannelID: options.ChannelID, AuthorID: options.AuthorID, Messages: make([]ModelMessage, 0)}
+
+	if options.CheckpointFile == "" {
+		return checkpoint, nil
+	}
+
+	checkpointFile, err := os.OpenFile(options.CheckpointFile, os.O_RDWR|os.O_CREATE, 0660)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open fetch checkpoint %s: %v", options.CheckpointFile, err)
+	}
+
+	if err := checkpoint.read(checkpointFile); err != nil {
+		checkpointFile.Close()
+		return nil, err
+	}
+
+	checkpoint.file = checkpointFile
+	return checkpoint, nil
+}
+
+// read reads the header & pages of a checkpoint file, an empty file gets the header of the checkpoint.
+// A page cut short by a crash is removed, the fetch continues from the page before it
+func (checkpoint *fetchCheckpoint) read(checkpointFile *os.File) error {
+	checkpointPath := checkpointFile.Name()
+	dec := json.NewDecoder(checkpointFile)
+
+	saved := &fetchCheckpoint{}
+	if err := dec.Decode(saved); err == io.EOF {
+		return writeCheckpointRecord(checkpointFile, checkpoint)
+	} else if err != nil {
+		return fmt.Errorf("failed to decode fetch checkpoint %s, remove it to start over: %v", checkpointPath, err)
+	}
+
+	if saved.ChannelID != checkpoint.ChannelID || saved.AuthorID != checkpoint.AuthorID {
+		return fmt.Errorf("fetch checkpoint %s is from fetching another channel or author, remove it to start over",
+			checkpointPath)
+	}
+
+	for {
+		// the end of the last complete page
+		offset := dec.InputOffset()
+
+		var page fetchCheckpointPage
+		if err := dec.Decode(&page); err != nil {
+			if err != io.EOF {
+				logger.Warn("Removing incomplete page from fetch checkpoint", "file", checkpointPath, "error", err)
+			}
+
+			if err := checkpointFile.Truncate(offset); err != nil {
+				return fmt.Errorf("failed to remove incomplete page from fetch checkpoint %s: %v", checkpointPath, err)
+			}
+			if _, err := checkpointFile.Seek(offset, io.SeekStart); err != nil {
+				return fmt.Errorf("failed to seek fetch checkpoint %s: %v", checkpointPath, err)
+			}
+			return nil
+		}
+
+		checkpoint.Before = page.Before
+		checkpoint.Messages = append(checkpoint.Messages, page.Messages...)
+	}
+}
+
+// writeCheckpointRecord writes a header or a page to the end of a checkpoint file & syncs it to the disk
+func writeCheckpointRecord(checkpointFile *os.File, record interface{}) error {
+	if err := json.NewEncoder(checkpointFile).Encode(record); err != nil {
+		return fmt.Errorf("failed to write fetch checkpoint %s: %v", checkpointFile.Name(), err)
+	}
+	if err := checkpointFile.Sync(); err != nil {
+		return fmt.Errorf("failed to sync fetch checkpoint %s: %v", checkpointFile.Name(), err)
+	}
+	return nil
+}
+
+// addPage adds the messages kept from a page to the checkpoint & saves the page to the checkpoint file
+func (checkpoint *fetchCheckpoint) addPage(page fetchCheckpointPage) error {
+	checkpoint.Before = page.Before
+	checkpoint.Messages = append(checkpoint.Messages, page.Messages...)
+
+	if checkpoint.file == nil {
+		return nil
+	}
+	return writeCheckpointRecord(checkpoint.file, page)
+}
+
+// close closes the checkpoint file
+func (checkpoint *fetchCheckpoint) close() {
+	if checkpoint.file == nil {
+		return
+	}
+
+	if err := checkpoint.file.Close(); err != nil {
+		logger.Warn("Failed to close fetch checkpoint", "file", checkpoint.file.Name(), "error", err)
+	}
+}
+
+// FetchChannelMessages pages through the messages of a channel from the newest to the oldest.
+// Every page is added to the checkpoint file, so a fetch that fails can be continued by running it again.
+// Rate limits are waited out by the session
+func FetchChannelMessages(s messageFetcher, options FetchOptions) ([]ModelMessage, error) {
+	checkpoint, err := loadFetchCheckpoint(options)
+	if err != nil {
+		return nil, err
+	}
+	defer checkpoint.close()
+
+	if options.RateLimited != nil {
+		messagesEndpoint := discordgo.EndpointChannelMessages(options.ChannelID)
+		removeHandler := s.AddHandler(func(s *discordgo.Session, r *discordgo.RateLimit) {
+			if strings.HasPrefix(r.URL, messagesEndpoint) {
+				options.RateLimited(r.RetryAfter)
+			}
+		})
+		defer removeHandler()
+	}
+
+	for options.Limit <= 0 || len(checkpoint.Messages) < options.Limit {
+		if options.Context != nil && options.Context.Err() != nil {
+			return nil, fmt.Errorf("fetching channel %s stopped after %d messages: %v",
+				options.ChannelID, len(checkpoint.Messages), options.Context.Err())
+		}
+
+		page, err := s.ChannelMessages(options.ChannelID, fetchPageSize, checkpoint.Before, "", "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch messages of channel %s after %d messages: %v",
+				options.ChannelID, len(checkpoint.Messages), err)
+		}
+
+		// the first message is reached
+		if len(page) == 0 {
+			break
+		}
+
+		// the messages are from the newest to the oldest
+		checkpointPage := fetchCheckpointPage{Before: page[len(page)-1].ID, Messages: make([]ModelMessage, 0, len(page))}
+
+		for _, message := range page {
+			if fetchKeepsMessage(options, message) == false {
+				continue
+			}
+
+			if modelMessage, ok := modelMessageFromDiscord(message); ok {
+				checkpointPage.Messages = append(checkpointPage.Messages, modelMessage)
+			}
+		}
+
+		if err := checkpoint.addPage(checkpointPage); err != nil {
+			return nil, err
+		}
+
+		if options.Progress != nil {
+			options.Progress(len(checkpoint.Messages))
+		}
+
+		if len(page) < fetchPageSize {
+			break
+		}
+	}
+
+	if options.Limit > 0 && len(checkpoint.Messages) > options.Limit {
+		checkpoint.Messages = checkpoint.Messages[:options.Limit]
+	}
+
+	return checkpoint.Messages, nil
+}
+
+// fetchKeepsMessage checks if a fetched message should be added to the model
+func fetchKeepsMessage(options FetchOptions, message *discordgo.Message) bool {
+	if message.Author == nil {
+		return false
+	}
+
+	if options.AuthorID != "" {
+		if message.Author.ID != options.AuthorID {
+			return false
+		}
+	} else if message.Author.Bot {
+		return false
+	}
+
+	return options.Consent == nil || options.Consent.IsOptedOut(message.Author.ID) == false
+}
+
+// FetchModel fetches the messages of a channel & saves them as a new model to modelPath.
+// The checkpoint file defaults to modelPath with .fetch added & is removed once the model is saved
+func FetchModel(s messageFetcher, options FetchOptions, name string, description string, modelPath string) (*WordModel, error) {
+	channelID, err := strconv.Atoi(options.ChannelID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid channel ID %s: %v", options.ChannelID, err)
+	}
+
+	// an interrupted fetch is continued, but a finished model is never replaced
+	if _, err := os.Stat(modelPath); err == nil {
+		return nil, fmt.Errorf("model file %s already exists", modelPath)
+	}
+
+	modelID := ModelIDFromFileName(modelPath)
+	if otherPath, err := modelIDUsedBy(path.Dir(modelPath), modelID, modelPath); err != nil {
+		return nil, err
+	} else if otherPath != "" {
+		return nil, fmt.Errorf("model ID %s is already used by %s, choose another filename", modelID, otherPath)
+	}
+
+	if options.CheckpointFile == "" {
+		options.CheckpointFile = modelPath + ".fetch"
+	}
+
+	messages, err := FetchChannelMessages(s, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		name = modelID
+	}
+
+	wordModel := &WordModel{
+		ID:          modelID,
+		Name:        name,
+		Description: description,
+		Channels:    []int{channelID},
+		Messages:    messages,
+	}
+	wordModel.RebuildWords()
+
+	if len(wordModel.Words) < 1 {
+		return nil, fmt.Errorf("no messages with words were found in channel %s", options.ChannelID)
+	}
+
+	if err := SaveModel(wordModel, modelPath); err != nil {
+		return nil, err
+	}
+
+	if err := os.Remove(options.CheckpointFile); err != nil && os.IsNotExist(err) == false {
+		return wordModel, fmt.Errorf("model saved but failed to remove fetch checkpoint %s: %v", options.CheckpointFile, err)
+	}
+
+	return wordModel, nil
+}
+
+// FetchModelFromChannel fetches the messages of a channel with the bot token from the config
+// & saves them as a new model to the model directory
+func FetchModelFromChannel(options FetchOptions, name string, description string, fileName string) error {
+	// try to load config from default location
+	if err := ConfigLoadConfig(nil); err != nil || LoadedConfig.AuthenticationToken == "" {
+		return fmt.Errorf("the bot token is needed for fetching messages, set it in the config")
+	}
+
+	bot, err := discordgo.New("Bot " + LoadedConfig.AuthenticationToken)
+	if err != nil {
+		return fmt.Errorf("failed to create Discord session: %v", err)
+	}
+
+	consentFile, err := DefaultConsentFile()
+	if err != nil {
+		return err
+	}
+
+	options.Consent, err = LoadConsentRegistry(consentFile)
+	if err != nil {
+		return err
+	}
+
+	options.Progress = func(kept int) {
+		logger.Info("Fetched messages", "messages", kept)
+	}
+	options.RateLimited = func(wait time.Duration) {
+		logger.Warn("Rate limited", "wait", wait)
+	}
+
+	saveDirectory, err := DefaultModelDirectory()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(saveDirectory, 0770); err != nil {
+		return fmt.Errorf("failed to create models directory at %s: %v", saveDirectory, err)
+	}
+
+	if strings.HasSuffix(fileName, ".gob") == false {
+		fileName = fileName + ".gob"
+	}
+	modelPath := path.Join(saveDirectory, fileName)
+
+	logger.Info("Fetching messages", "channel", options.ChannelID, "file", modelPath)
+
+	wordModel, err := FetchModel(bot, options, name, description, modelPath)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Saved model", "model", wordModel.ID, "messages", len(wordModel.Messages), "words", len(wordModel.Words))
+	return nil
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/bwmarrin/discordgo"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDiscord serves the messages of a channel like the Discord API does
+type fakeDiscord struct {
+	mutex sync.Mutex
+	// messages of the channel from the oldest to the newest
+	messages []*discordgo.Message
+	// requests served so far
+	requests int
+	// request that fails with a server error, 0 for none
+	failRequest int
+	// request that is rate limited once, 0 for none
+	rateLimitRequest int
+	// before parameter of every request
+	befores []string
+}
+
+func (fake *fakeDiscord) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	fake.requests++
+
+	if fake.requests == fake.rateLimitRequest {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"message": "You are being rate limited.", "retry_after": 0.01, "global": false}`))
+		return
+	}
+
+	if fake.requests == fake.failRequest {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	before := r.URL.Query().Get("before")
+	fake.befores = append(fake.befores, before)
+
+	// newest first, like Discord
+	page := make([]*discordgo.Message, 0, limit)
+	for index := len(fake.messages) - 1; index >= 0 && len(page) < limit; index-- {
+		message := fake.messages[index]
+		if before != "" && message.ID >= before {
+			continue
+		}
+		page = append(page, message)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// redirectTransport sends every request to a test server
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (transport redirectTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r.URL.Scheme = transport.target.Scheme
+	r.URL.Host = transport.target.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func newFakeDiscord(t *testing.T) (*fakeDiscord, *discordgo.Session) {
+	fake := &fakeDiscord{}
+	for index := 0; index < 250; index++ {
+		author := &discordgo.User{ID: strconv.Itoa(index%2 + 1)}
+		if index == 100 {
+			author = &discordgo.User{ID: "3", Bot: true}
+		}
+
+		fake.messages = append(fake.messages, &discordgo.Message{
+			ID:        strconv.Itoa(1000 + index),
+			ChannelID: "42",
+			Author:    author,
+			Content:   "message number " + strconv.Itoa(index),
+			Timestamp: time.Date(2022, 1, 1, 0, index, 0, 0, time.UTC),
+		})
+	}
+
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	target, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := discordgo.New("Bot test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Client = &http.Client{Transport: redirectTransport{target: target}}
+	s.MaxRestRetries = 0
+	// run the rate limit handler before the request is retried
+	s.SyncEvents = true
+
+	return fake, s
+}
+
+func TestFetchChannelMessages(t *testing.T) {
+	fake, s := newFakeDiscord(t)
+	fake.rateLimitRequest = 2
+
+	rateLimited := 0
+	messages, err := FetchChannelMessages(s, FetchOptions{
+		ChannelID: "42",
+		RateLimited: func(wait time.Duration) {
+			rateLimited++
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the message of the bot is skipped
+	if len(messages) != 249 {
+		t.Errorf("expected 249 messages, got %d", len(messages))
+	}
+	if rateLimited != 1 {
+		t.Errorf("expected to be rate limited once, got %d", rateLimited)
+	}
+	if messages[0].ID != 1249 || messages[0].ChannelID != 42 || messages[0].AuthorID != "2" {
+		t.Errorf("expected newest message first, got %+v", messages[0])
+	}
+
+	authorMessages, err := FetchChannelMessages(s, FetchOptions{ChannelID: "42", AuthorID: "1", Limit: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(authorMessages) != 50 {
+		t.Errorf("expected 50 messages with a limit, got %d", len(authorMessages))
+	}
+	for _, message := range authorMessages {
+		if message.AuthorID != "1" {
+			t.Errorf("expected only messages of author 1, got message from %s", message.AuthorID)
+		}
+	}
+}
+
+func TestFetchModelResume(t *testing.T) {
+	testDir, err := os.MkdirTemp(os.TempDir(), "hurabotTestFetchModel")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake, s := newFakeDiscord(t)
+	fake.failRequest = 2
+
+	modelPath := path.Join(testDir, "fetched.gob")
+	checkpointPath := modelPath + ".fetch"
+
+	if _, err := FetchModel(s, FetchOptions{ChannelID: "42"}, "Fetched", "", modelPath); err == nil {
+		t.Fatal("expected the fetch to fail")
+	}
+
+	if _, err := os.Stat(checkpointPath); err != nil {
+		t.Fatalf("checkpoint wasn't saved after the failed fetch: %v", err)
+	}
+
+	// a page cut short by a crash is ignored
+	checkpointFile, err := os.OpenFile(checkpointPath, os.O_WRONLY|os.O_APPEND, 0660)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := checkpointFile.WriteString(`{"Before": "1050", "Messages": [{"ID": 10`); err != nil {
+		t.Fatal(err)
+	}
+	checkpointFile.Close()
+
+	// the fetch continues from the oldest message of the first page
+	wordModel, err := FetchModel(s, FetchOptions{ChannelID: "42"}, "Fetched", "", modelPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lastBefore := fake.befores[len(fake.befores)-2]; lastBefore != "1150" {
+		t.Errorf("expected the fetch to continue before message 1150, got %s", lastBefore)
+	}
+	if len(wordModel.Messages) != 249 || wordModel.ID != "fetched" || wordModel.Name != "Fetched" {
+		t.Errorf("expected model fetched with 249 messages, got %s with %d", wordModel.ID, len(wordModel.Messages))
+	}
+	if len(wordModel.Contributors) != 2 {
+		t.Errorf("expected 2 contributors, got %v", wordModel.Contributors)
+	}
+
+	if _, err := os.Stat(checkpointPath); os.IsNotExist(err) == false {
+		t.Error("checkpoint wasn't removed after the model was saved")
+	}
+
+	if _, err := os.Stat(modelPath); err != nil {
+		t.Errorf("model wasn't saved: %v", err)
+	}
+
+	// finished models aren't replaced
+	if _, err := FetchModel(s, FetchOptions{ChannelID: "42"}, "Fetched", "", modelPath); err == nil {
+		t.Error("expected fetching over an existing model to fail")
+	}
+
+	// models with the ID of another model aren't fetched
+	if _, err := FetchModel(s, FetchOptions{ChannelID: "42"}, "Fetched", "", path.Join(testDir, "Fetched.gob")); err == nil {
+		t.Error("expected fetching a model with an ID that is already used to fail")
+	}
+
+	if err := os.RemoveAll(testDir); err != nil {
+		t.Logf("failed to remove the test directory %s: %v", testDir, err)
+	}
+}
+
+func TestFetchCheckpointPages(t *testing.T) {
+	testDir, err := os.MkdirTemp(os.TempDir(), "hurabotTestFetchCheckpointPages")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := FetchOptions{ChannelID: "42", CheckpointFile: path.Join(testDir, "fetched.gob.fetch")}
+
+	checkpoint, err := loadFetchCheckpoint(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for page := 0; page < 3; page++ {
+		if err := checkpoint.addPage(fetchCheckpointPage{
+			Before:   strconv.Itoa(1000 - page*100),
+			Messages: []ModelMessage{{ID: 1000 - page*100, ChannelID: 42, Words: []string{"hello"}}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkpoint.close()
+
+	// every page is a line of its own after the header, the earlier pages aren't written again
+	checkpointContents, err := os.ReadFile(options.CheckpointFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(checkpointContents), "\n"); lines != 4 {
+		t.Errorf("expected a header & 3 pages in the checkpoint, got %d lines", lines)
+	}
+
+	checkpoint, err = loadFetchCheckpoint(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpoint.close()
+
+	if checkpoint.Before != "800" || len(checkpoint.Messages) != 3 {
+		t.Errorf("expected 3 messages & to continue before 800, got %d & %s", len(checkpoint.Messages), checkpoint.Before)
+	}
+
+	// checkpoints of other channels aren't continued
+	if _, err := loadFetchCheckpoint(FetchOptions{ChannelID: "43", CheckpointFile: options.CheckpointFile}); err == nil {
+		t.Error("expected the checkpoint of another channel to be refused")
+	}
+
+	if err := os.RemoveAll(testDir); err != nil {
+		t.Logf("failed to remove the test directory %s: %v", testDir, err)
+	}
+}
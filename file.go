@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+)
+
+// WriteFileAtomic writes a file by writing to a temporary file first, syncing it to disk & renaming it over the file,
+// so the file is never left half written. If keepBackup is set, the previous version is kept as a .bak file
+func WriteFileAtomic(filePath string, perm os.FileMode, keepBackup bool, write func(w io.Writer) error) error {
+	tempFile, err := os.CreateTemp(path.Dir(filePath), path.Base(filePath)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for %s: %v", filePath, err)
+	}
+
+	// remove the temporary file if anything goes wrong
+	success := false
+	defer func() {
+		if success == false {
+			tempFile.Close()
+			os.Remove(tempFile.Name())
+		}
+	}()
+
+	if err := write(tempFile); err != nil {
+		return err
+	}
+
+	if err := tempFile.Sync(); err != nil {
+		return fmt.Errorf("failed to sync temporary file %s: %v", tempFile.Name(), err)
+	}
+
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file %s: %v", tempFile.Name(), err)
+	}
+
+	if err := os.Chmod(tempFile.Name(), perm); err != nil {
+		return fmt.Errorf("failed to set permissions of temporary file %s: %v", tempFile.Name(), err)
+	}
+
+	if keepBackup == true {
+		if err := backupFile(filePath); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(tempFile.Name(), filePath); err != nil {
+		return fmt.Errorf("failed to replace %s: %v", filePath, err)
+	}
+	success = true
+
+	// sync the directory so the rename is on disk too
+	if directory, err := os.Open(path.Dir(filePath)); err == nil {
+		if err := directory.Sync(); err != nil {
+			logger.Warn("Failed to sync directory", "directory", directory.Name(), "error", err)
+		}
+		directory.Close()
+	}
+
+	return nil
+}
+
+// backupFile copies a file to a .bak file next to it, replacing any older backup. Missing and empty files are skipped
+func backupFile(filePath string) error {
+	fileInfo, err := os.Stat(filePath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to stat %s for backup: %v", filePath, err)
+	}
+
+	if fileInfo.Size() == 0 {
+		return nil
+	}
+
+	backupPath := filePath + ".bak"
+
+	if err := os.Remove(backupPath); err != nil && os.IsNotExist(err) == false {
+		return fmt.Errorf("failed to remove old backup %s: %v", backupPath, err)
+	}
+
+	// hard link the old version, the new one is renamed over the original path so the link keeps the old contents
+	if err := os.Link(filePath, backupPath); err == nil {
+		return nil
+	}
+
+	// linking isn't supported everywhere, copy the file instead
+	return WriteFileAtomic(backupPath, fileInfo.Mode().Perm(), false, func(w io.Writer) error {
+		source, err := os.Open(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to open %s for backup: %v", filePath, err)
+		}
+		defer source.Close()
+
+		if _, err := io.Copy(w, source); err != nil {
+			return fmt.Errorf("failed to copy %s to %s: %v", filePath, backupPath, err)
+		}
+		return nil
+	})
+}
+
+// keepBackups checks if the loaded config wants backups of overwritten files
+func keepBackups() bool {
+	return LoadedConfig != nil && LoadedConfig.KeepBackups
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	testDir, err := os.MkdirTemp(os.TempDir(), "hurabotTestWriteFileAtomic")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testFilePath := path.Join(testDir, "test.json")
+
+	writeString := func(content string) func(w io.Writer) error {
+		return func(w io.Writer) error {
+			_, err := io.WriteString(w, content)
+			return err
+		}
+	}
+
+	if err := WriteFileAtomic(testFilePath, 0660, true, writeString("first")); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+
+	if err := WriteFileAtomic(testFilePath, 0660, true, writeString("second")); err != nil {
+		t.Fatalf("failed to overwrite test file: %v", err)
+	}
+
+	// a failing write must leave the file as it was
+	if err := WriteFileAtomic(testFilePath, 0660, true, func(w io.Writer) error {
+		if _, err := io.WriteString(w, "broken"); err != nil {
+			return err
+		}
+		return errors.New("test error")
+	}); err == nil {
+		t.Errorf("failing write didn't return an error")
+	}
+
+	content, err := os.ReadFile(testFilePath)
+
+	if err != nil {
+		t.Errorf("failed to read test file: %v", err)
+	} else if string(content) != "second" {
+		t.Errorf("test file contains %s instead of second", content)
+	}
+
+	backupContent, err := os.ReadFile(testFilePath + ".bak")
+
+	if err != nil {
+		t.Errorf("failed to read backup file: %v", err)
+	} else if string(backupContent) != "first" {
+		t.Errorf("backup file contains %s instead of first", backupContent)
+	}
+
+	// only the file & its backup should be left
+	dirContents, err := os.ReadDir(testDir)
+
+	if err != nil {
+		t.Errorf("failed to read test directory: %v", err)
+	} else if len(dirContents) != 2 {
+		t.Errorf("test directory has %d files instead of 2", len(dirContents))
+	}
+
+	if err := os.RemoveAll(testDir); err != nil {
+		t.Logf("failed to remove the test directory %s: %v", testDir, err)
+	}
+}
@@ -1,6 +1,6 @@
 module hurabot
 
-go 1.18
+go 1.21
 
 require (
 	github.com/akamensky/argparse v1.3.1
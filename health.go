@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// 1 while the gateway connection is open
+	gatewayConnected int32
+	// time of the last interaction that was responded to in Unix nanoseconds, 0 if there hasn't been any
+	lastInteraction int64
+)
+
+// healthStatus the state of the bot reported by the health checks
+type healthStatus struct {
+	// Status ok if the check passed, otherwise why it didn't
+	Status string `json:"status"`
+	// GatewayConnected whether the gateway connection is open
+	GatewayConnected bool `json:"gateway_connected"`
+	// Models amount of models found
+	Models int `json:"models"`
+	// LastInteraction time of the last interaction that was responded to, nil if there hasn't been any
+	LastInteraction *time.Time `json:"last_interaction"`
+}
+
+// recordInteraction marks an interaction as responded to
+func recordInteraction() {
+	atomic.StoreInt64(&lastInteraction, time.Now().UnixNano())
+}
+
+// currentHealth returns the current state of the bot with an ok status
+func currentHealth() healthStatus {
+	modelsMutex.RLock()
+	modelCount := len(botModels)
+	modelsMutex.RUnlock()
+
+	status := healthStatus{
+		Status:           "ok",
+		GatewayConnected: atomic.LoadInt32(&gatewayConnected) == 1,
+		Models:           modelCount,
+	}
+
+	if last := atomic.LoadInt64(&lastInteraction); last != 0 {
+		lastTime := time.Unix(0, last)
+		status.LastInteraction = &lastTime
+	}
+	return status
+}
+
+// writeHealth responds with the status as JSON, with 503 Service Unavailable if the check didn't pass
+func writeHealth(w http.ResponseWriter, status healthStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	if status.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		logger.Warn("Failed to write health status", "error", err)
+	}
+}
+
+// healthHandler serves /healthz, which passes while the bot is running & not shutting down
+func healthHandler(w http.ResponseWriter, _ *http.Request) {
+	status := currentHealth()
+	if botContext.Err() != nil {
+		status.Status = "shutting down"
+	}
+	writeHealth(w, status)
+}
+
+// readyHandler serves /readyz, which passes when the bot is connected to the gateway & has models to use
+func readyHandler(w http.ResponseWriter, _ *http.Request) {
+	status := currentHealth()
+	switch {
+	case botContext.Err() != nil:
+		status.Status = "shutting down"
+	case status.GatewayConnected == false:
+		status.Status = "gateway not connected"
+	case status.Models < 1:
+		status.Status = "no models found"
+	}
+	writeHealth(w, status)
+}
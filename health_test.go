@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// checkHealth calls a health check handler & returns the status code & the decoded status
+func checkHealth(t *testing.T, handler http.HandlerFunc) (int, healthStatus) {
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var status healthStatus
+	if err := json.Unmarshal(recorder.Body.Bytes(), &status); err != nil {
+		t.Fatalf("health status is not JSON: %v", err)
+	}
+	return recorder.Code, status
+}
+
+func TestHealthChecks(t *testing.T) {
+	setupBotTest(t, &MainBotConfig{}, "word")
+
+	oldContext := botContext
+	defer func() {
+		botContext = oldContext
+		atomic.StoreInt32(&gatewayConnected, 0)
+		atomic.StoreInt64(&lastInteraction, 0)
+	}()
+	botContext = context.Background()
+	atomic.StoreInt32(&gatewayConnected, 0)
+	atomic.StoreInt64(&lastInteraction, 0)
+
+	code, status := checkHealth(t, healthHandler)
+	if code != http.StatusOK || status.Status != "ok" || status.Models != 1 || status.LastInteraction != nil {
+		t.Errorf("unexpected health %d %+v", code, status)
+	}
+
+	code, status = checkHealth(t, readyHandler)
+	if code != http.StatusServiceUnavailable || status.Status != "gateway not connected" {
+		t.Errorf("expected not ready without a gateway connection, got %d %+v", code, status)
+	}
+
+	atomic.StoreInt32(&gatewayConnected, 1)
+	recordInteraction()
+
+	code, status = checkHealth(t, readyHandler)
+	if code != http.StatusOK || status.GatewayConnected == false || status.LastInteraction == nil {
+		t.Errorf("expected ready, got %d %+v", code, status)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	botContext = ctx
+
+	for _, handler := range []http.HandlerFunc{healthHandler, readyHandler} {
+		code, status = checkHealth(t, handler)
+		if code != http.StatusServiceUnavailable || status.Status != "shutting down" {
+			t.Errorf("expected the checks to fail while shutting down, got %d %+v", code, status)
+		}
+	}
+}
+
+func TestStartHTTPServers(t *testing.T) {
+	setupBotTest(t, &MainBotConfig{MetricsListenAddress: "127.0.0.1:0", HealthListenAddress: "127.0.0.1:0"}, "word")
+
+	servers, err := startHTTPServers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, server := range servers {
+		server.Close()
+	}
+
+	if len(servers) != 1 {
+		t.Errorf("expected the metrics & health checks to share a server, got %d servers", len(servers))
+	}
+
+	LoadedConfig = &MainBotConfig{}
+	servers, err = startHTTPServers()
+	if err != nil || len(servers) != 0 {
+		t.Errorf("expected no servers without addresses, got %d: %v", len(servers), err)
+	}
+}
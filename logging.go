@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// logger for writing to log file, writes to stderr until the bot sets it up
+var logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+// logFileWriter writes to a log file until it's closed, writes after that are dropped
+type logFileWriter struct {
+	// the log file, nil when closed
+	file *os.File
+	// lock for writing & closing at the same time
+	mutex sync.Mutex
+}
+
+// Write writes to the log file if it's still open
+func (w *logFileWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.file == nil {
+		return len(p), nil
+	}
+	return w.file.Write(p)
+}
+
+// Close closes the log file, handlers still running can keep logging to the other writers
+func (w *logFileWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.file.Close()
+	if err != nil {
+		err = fmt.Errorf("failed to close log file %s: %v", w.file.Name(), err)
+	}
+	w.file = nil
+	return err
+}
+
+// parseLogLevel returns the level of a LogLevel config option. Empty and "default" are info,
+// "verbose" is kept for older configs & is the same as debug
+func parseLogLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "debug", "verbose":
+		return slog.LevelDebug, nil
+	case "", "default", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unknown log level %s, use debug, info, warn or error", level)
+}
+
+// newLogger makes a logger writing to w with the level & format of the loaded config
+func newLogger(w io.Writer) (*slog.Logger, error) {
+	level, format := "", ""
+	if LoadedConfig != nil {
+		level, format = LoadedConfig.LogLevel, LoadedConfig.LogFormat
+	}
+
+	logLevel, err := parseLogLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	options := &slog.HandlerOptions{Level: logLevel}
+
+	switch strings.ToLower(format) {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	}
+	return nil, fmt.Errorf("unknown log format %s, use text or json", format)
+}
+
+// setCLILogger makes the command line commands log with the settings of the default config, if there is one
+func setCLILogger() {
+	if LoadedConfig == nil {
+		// commands work without a config, the defaults are used then
+		_ = ConfigLoadConfig(nil)
+	}
+
+	cliLogger, err := newLogger(os.Stderr)
+	if err != nil {
+		logger.Warn("Invalid logging settings in the config, using the defaults", "error", err)
+		return
+	}
+	logger = cliLogger
+}
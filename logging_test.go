@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	levels := map[string]slog.Level{
+		"":        slog.LevelInfo,
+		"default": slog.LevelInfo,
+		"info":    slog.LevelInfo,
+		"debug":   slog.LevelDebug,
+		"verbose": slog.LevelDebug,
+		"WARN":    slog.LevelWarn,
+		"warning": slog.LevelWarn,
+		"error":   slog.LevelError,
+	}
+
+	for name, want := range levels {
+		level, err := parseLogLevel(name)
+		if err != nil {
+			t.Errorf("failed to parse log level %q: %v", name, err)
+		}
+		if level != want {
+			t.Errorf("log level %q was parsed as %v, expected %v", name, level, want)
+		}
+	}
+
+	if _, err := parseLogLevel("loud"); err == nil {
+		t.Error("unknown log level was accepted")
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	oldConfig := LoadedConfig
+	defer func() {
+		LoadedConfig = oldConfig
+	}()
+
+	LoadedConfig = &MainBotConfig{LogLevel: "warn", LogFormat: "json"}
+
+	var output bytes.Buffer
+	testLogger, err := newLogger(&output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testLogger.Info("Not logged")
+	testLogger.Warn("Logged", "model", "test")
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 log line, got %d: %q", len(lines), output.String())
+	}
+
+	var line map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &line); err != nil {
+		t.Fatalf("log line is not JSON: %v", err)
+	}
+	if line["level"] != "WARN" || line["msg"] != "Logged" || line["model"] != "test" {
+		t.Errorf("unexpected log line %v", line)
+	}
+
+	LoadedConfig.LogFormat = "xml"
+	if _, err := newLogger(&output); err == nil {
+		t.Error("unknown log format was accepted")
+	}
+}
+
+func TestLogFileWriter(t *testing.T) {
+	testDir, err := os.MkdirTemp(os.TempDir(), "hurabotTestLogFileWriter")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logPath := path.Join(testDir, "test.log")
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	writer := &logFileWriter{file: logFile}
+	testLogger := slog.New(slog.NewTextHandler(io.MultiWriter(writer, &stdout), nil))
+
+	testLogger.Info("before closing")
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the logger keeps working after the file is closed
+	testLogger.Info("after closing")
+
+	logContents, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(logContents), "before closing") == false || strings.Contains(string(logContents), "after closing") {
+		t.Errorf("expected only the message before closing in the log file, got %q", logContents)
+	}
+	if strings.Contains(stdout.String(), "after closing") == false {
+		t.Errorf("expected the message after closing in the other writer, got %q", stdout.String())
+	}
+
+	if err := os.RemoveAll(testDir); err != nil {
+		t.Logf("failed to remove the test directory %s: %v", testDir, err)
+	}
+}
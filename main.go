@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"github.com/akamensky/argparse"
 	"os"
+	"regexp"
+	"strings"
 )
 
 func main() {
@@ -15,10 +17,17 @@ func main() {
 	modelCommandModelFileOptions := &argparse.Options{
 		Required: true,
 		Validate: nil,
-		Help:     "Model file to use",
+		Help:     "Model file or ID to use",
 		Default:  nil,
 	}
 
+	modelCommandAuthorOptions := &argparse.Options{
+		Required: false,
+		Validate: nil,
+		Help:     "Discord user ID of the author of the messages, read from the data export if not given",
+		Default:  "",
+	}
+
 	// model creation command
 	modelCommandCreate := modelCommand.NewCommand("create", "create new model from discord messages")
 	modelCommandCreateArgs := modelCommandCreate.File("d", "directory", os.O_RDONLY, 0660, &argparse.Options{
@@ -27,14 +36,109 @@ func main() {
 		Help:     "Discord messages folder to process",
 		Default:  nil,
 	})
+	modelCommandCreateAuthorArg := modelCommandCreate.String("a", "author", modelCommandAuthorOptions)
+
+	// model update command
+	modelCommandUpdate := modelCommand.NewCommand("update", "add new messages from a newer Discord data export to a model")
+	modelCommandUpdateModelArg := modelCommandUpdate.String("m", "model", modelCommandModelFileOptions)
+	modelCommandUpdateDirectoryArg := modelCommandUpdate.File("d", "directory", os.O_RDONLY, 0660, &argparse.Options{
+		Required: true,
+		Validate: nil,
+		Help:     "Discord messages folder of the newer data export",
+		Default:  nil,
+	})
+	modelCommandUpdateAuthorArg := modelCommandUpdate.String("a", "author", modelCommandAuthorOptions)
+
+	// model fetch command
+	modelCommandFetch := modelCommand.NewCommand("fetch", "create new model from the messages of a channel with the bot token")
+	modelCommandFetchChannelArg := modelCommandFetch.String("c", "channel", &argparse.Options{
+		Required: true,
+		Validate: nil,
+		Help:     "ID of the channel to fetch",
+		Default:  nil,
+	})
+	modelCommandFetchFileArg := modelCommandFetch.String("f", "file", &argparse.Options{
+		Required: true,
+		Validate: nil,
+		Help:     "Filename of the new model in the model directory, run again with the same filename to continue an interrupted fetch",
+		Default:  nil,
+	})
+	modelCommandFetchAuthorArg := modelCommandFetch.String("a", "author", &argparse.Options{
+		Required: false,
+		Validate: nil,
+		Help:     "Only use the messages of this Discord user ID",
+		Default:  "",
+	})
+	modelCommandFetchLimitArg := modelCommandFetch.Int("l", "limit", &argparse.Options{
+		Required: false,
+		Validate: nil,
+		Help:     "Maximum amount of messages to use, 0 for every message",
+		Default:  0,
+	})
+	modelCommandFetchNameArg := modelCommandFetch.String("n", "name", &argparse.Options{
+		Required: false,
+		Validate: nil,
+		Help:     "Name of the model",
+		Default:  "",
+	})
+	modelCommandFetchDescriptionArg := modelCommandFetch.String("t", "description", &argparse.Options{
+		Required: false,
+		Validate: nil,
+		Help:     "Description of the model",
+		Default:  "",
+	})
+
+	// model prune command
+	modelCommandPrune := modelCommand.NewCommand("prune", "remove words, phrases or messages from a model")
+	modelCommandPruneModelArg := modelCommandPrune.String("m", "model", modelCommandModelFileOptions)
+	modelCommandPrunePhrasesArg := modelCommandPrune.StringList("w", "word", &argparse.Options{
+		Required: false,
+		Validate: nil,
+		Help:     "Word or phrase to remove, can be given multiple times",
+		Default:  nil,
+	})
+	modelCommandPruneRegexArg := modelCommandPrune.StringList("r", "regex", &argparse.Options{
+		Required: false,
+		Validate: nil,
+		Help:     "Regular expression for words to remove, can be given multiple times",
+		Default:  nil,
+	})
+	modelCommandPruneChannelArg := modelCommandPrune.IntList("c", "channel", &argparse.Options{
+		Required: false,
+		Validate: nil,
+		Help:     "ID of a channel whose messages to remove, can be given multiple times",
+		Default:  nil,
+	})
+	modelCommandPruneFromArg := modelCommandPrune.String("f", "from", &argparse.Options{
+		Required: false,
+		Validate: nil,
+		Help:     "Remove messages sent on or after this date (YYYY-MM-DD)",
+		Default:  "",
+	})
+	modelCommandPruneUntilArg := modelCommandPrune.String("u", "until", &argparse.Options{
+		Required: false,
+		Validate: nil,
+		Help:     "Remove messages sent on or before this date (YYYY-MM-DD)",
+		Default:  "",
+	})
+
+	// model describe command
+	modelCommandDescribe := modelCommand.NewCommand("describe", "set the description of a model")
+	modelCommandDescribeModelArg := modelCommandDescribe.String("m", "model", modelCommandModelFileOptions)
+	modelCommandDescribeTextArg := modelCommandDescribe.String("t", "text", &argparse.Options{
+		Required: true,
+		Validate: nil,
+		Help:     "Description shown when choosing models in Discord",
+		Default:  nil,
+	})
 
 	// model show command
 	modelCommandShow := modelCommand.NewCommand("show", "show info from a model")
-	modelCommandShowArgs := modelCommandShow.FileList("m", "model", os.O_RDONLY, 0440, modelCommandModelFileOptions)
+	modelCommandShowArgs := modelCommandShow.StringList("m", "model", modelCommandModelFileOptions)
 
 	// model text generation command
 	modelCommandGenerate := modelCommand.NewCommand("generate", "Generate random text from a model")
-	modelCommandModelFileArg := modelCommandGenerate.File("m", "model", os.O_RDONLY, 0440, modelCommandModelFileOptions)
+	modelCommandModelFileArg := modelCommandGenerate.String("m", "model", modelCommandModelFileOptions)
 	modelCommandGenerateCountArg := modelCommandGenerate.Int("w", "words", &argparse.Options{
 		Required: false,
 		Validate: nil,
@@ -65,6 +169,15 @@ func main() {
 	botCommand := parser.NewCommand("run", "run the bot")
 	botCommandRunConfigArg := botCommand.File("c", "config-file", os.O_RDONLY, 0660, configCommandFileOptions)
 
+	// SLASH COMMAND OPTIONS
+	commandsCommand := parser.NewCommand("commands", "manage the bot's slash commands in Discord")
+
+	commandsCommandRegister := commandsCommand.NewCommand("register", "register the commands to the guilds in the config, only if they changed")
+	commandsCommandUnregister := commandsCommand.NewCommand("unregister", "remove the commands from the guilds in the config")
+
+	commandsCommandRegisterConfigFile := commandsCommandRegister.File("c", "config-file", os.O_RDONLY, 0660, configCommandFileOptions)
+	commandsCommandUnregisterConfigFile := commandsCommandUnregister.File("c", "config-file", os.O_RDONLY, 0660, configCommandFileOptions)
+
 	// END OF ARGUMENTS
 
 	err := parser.Parse(os.Args)
@@ -75,38 +188,147 @@ func main() {
 		return
 	}
 	// handle model commands
+	if modelCommand.Happened() {
+		setCLILogger()
+	}
 	if modelCommandCreate.Happened() {
+		ModelAuthorID = *modelCommandCreateAuthorArg
 		if err := CreateModel(modelCommandCreateArgs); err != nil {
 			fmt.Printf("Error creating model: %v\n", err)
 		}
 		return
 	}
+	if modelCommandUpdate.Happened() {
+		ModelAuthorID = *modelCommandUpdateAuthorArg
+		modelFile, err := OpenModelFile(*modelCommandUpdateModelArg)
+		if err != nil {
+			fmt.Printf("Error updating model: %v\n", err)
+			return
+		}
+		if err := UpdateModel(modelFile, modelCommandUpdateDirectoryArg); err != nil {
+			fmt.Printf("Error updating model: %v\n", err)
+		}
+		return
+	}
+	if modelCommandFetch.Happened() {
+		fetchOptions := FetchOptions{
+			ChannelID: *modelCommandFetchChannelArg,
+			AuthorID:  *modelCommandFetchAuthorArg,
+			Limit:     *modelCommandFetchLimitArg,
+		}
+		if err := FetchModelFromChannel(fetchOptions, *modelCommandFetchNameArg, *modelCommandFetchDescriptionArg,
+			*modelCommandFetchFileArg); err != nil {
+			fmt.Printf("Error fetching model: %v\n", err)
+		}
+		return
+	}
+	if modelCommandPrune.Happened() {
+		pruneOptions := PruneOptions{
+			Phrases:    *modelCommandPrunePhrasesArg,
+			ChannelIDs: *modelCommandPruneChannelArg,
+		}
+
+		for _, expression := range *modelCommandPruneRegexArg {
+			pattern, err := regexp.Compile(expression)
+			if err != nil {
+				fmt.Printf("Invalid regular expression %s: %v\n", expression, err)
+				return
+			}
+			pruneOptions.Patterns = append(pruneOptions.Patterns, pattern)
+		}
+
+		if *modelCommandPruneFromArg != "" {
+			pruneOptions.From, err = ParsePruneDate(*modelCommandPruneFromArg)
+			if err != nil {
+				fmt.Printf("Invalid date %s: %v\n", *modelCommandPruneFromArg, err)
+				return
+			}
+		}
+
+		if *modelCommandPruneUntilArg != "" {
+			until, err := ParsePruneDate(*modelCommandPruneUntilArg)
+			if err != nil {
+				fmt.Printf("Invalid date %s: %v\n", *modelCommandPruneUntilArg, err)
+				return
+			}
+			// include the whole day
+			pruneOptions.Until = until.AddDate(0, 0, 1)
+		}
+
+		if len(pruneOptions.Phrases) == 0 && len(pruneOptions.Patterns) == 0 && pruneOptions.removesMessages() == false {
+			fmt.Println("Nothing to prune, give words, regular expressions, channels or dates to remove")
+			return
+		}
+
+		modelFile, err := OpenModelFile(*modelCommandPruneModelArg)
+		if err != nil {
+			fmt.Printf("Error pruning model: %v\n", err)
+			return
+		}
+		if err := PruneModelFile(modelFile, pruneOptions); err != nil {
+			fmt.Printf("Error pruning model: %v\n", err)
+		}
+		return
+	}
+	if modelCommandDescribe.Happened() {
+		modelFile, err := OpenModelFile(*modelCommandDescribeModelArg)
+		if err != nil {
+			fmt.Printf("Error setting model description: %v\n", err)
+			return
+		}
+		if err := DescribeModel(modelFile, *modelCommandDescribeTextArg); err != nil {
+			fmt.Printf("Error setting model description: %v\n", err)
+		}
+		return
+	}
 	if modelCommandShow.Happened() {
 		if len(*modelCommandShowArgs) == 0 {
 			fmt.Println("No models provided")
 			return
 		}
 
-		for _, file := range *modelCommandShowArgs {
-			model, err := LoadModel(&file)
+		for _, pathOrID := range *modelCommandShowArgs {
+			file, err := OpenModelFile(pathOrID)
+
+			if err != nil {
+				fmt.Printf("Failed to open model %s: %v\n", pathOrID, err)
+				return
+			}
+
+			model, err := LoadModel(file)
+			file.Close()
 
 			if err != nil {
 				fmt.Printf("Failed to load model %s: %v", file.Name(), err.Error())
 				return
 			}
 
-			fmt.Printf("Model name: %s\n"+
-				"Model word count: %d\n",
-				model.Name, len(model.Words))
+			fmt.Printf("Model ID: %s\n"+
+				"Model name: %s\n"+
+				"Model description: %s\n"+
+				"Model word count: %d\n"+
+				"Model message count: %d\n"+
+				"Model channel count: %d\n"+
+				"Model contributors: %s\n",
+				model.ID, model.Name, model.Description, len(model.Words), len(model.Messages), len(model.Channels),
+				strings.Join(model.Contributors, ", "))
 
 		}
 		return
 	}
 	if modelCommandGenerate.Happened() {
-		wordModel, err := LoadModel(modelCommandModelFileArg)
+		modelFile, err := OpenModelFile(*modelCommandModelFileArg)
+
+		if err != nil {
+			fmt.Printf("Failed to open model %s: %v\n", *modelCommandModelFileArg, err)
+			return
+		}
+
+		wordModel, err := LoadModel(modelFile)
+		modelFile.Close()
 
 		if err != nil {
-			fmt.Println("Failed to load model " + modelCommandModelFileArg.Name())
+			fmt.Println("Failed to load model " + modelFile.Name())
 			return
 		}
 		fmt.Printf("Loaded %d words from model %s\n", len(wordModel.Words), wordModel.Name)
@@ -133,6 +355,28 @@ func main() {
 		return
 	}
 
+	// handle slash command commands
+	if commandsCommandRegister.Happened() {
+		if err := ConfigLoadConfig(commandsCommandRegisterConfigFile); err != nil {
+			fmt.Printf("Failed to load config from %s: %v\n", commandsCommandRegisterConfigFile.Name(), err)
+			return
+		}
+		if err := CommandsRegister(); err != nil {
+			fmt.Printf("Error registering commands: %v\n", err)
+		}
+		return
+	}
+	if commandsCommandUnregister.Happened() {
+		if err := ConfigLoadConfig(commandsCommandUnregisterConfigFile); err != nil {
+			fmt.Printf("Failed to load config from %s: %v\n", commandsCommandUnregisterConfigFile.Name(), err)
+			return
+		}
+		if err := CommandsUnregister(); err != nil {
+			fmt.Printf("Error removing commands: %v\n", err)
+		}
+		return
+	}
+
 	// handle bot commands
 	if botCommand.Happened() {
 		if err := ConfigLoadConfig(botCommandRunConfigArg); err != nil {
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// upper bounds in seconds of the generation time histogram buckets
+var generationBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+
+// commandKey labels of the received commands counter
+type commandKey struct {
+	Command string
+	Guild   string
+}
+
+// histogram counts observed values in buckets like a Prometheus histogram
+type histogram struct {
+	mutex   sync.Mutex
+	buckets []float64
+	// counts[i] is the amount of values at most buckets[i], the last one counts the values above every bucket
+	counts []int64
+	sum    float64
+	count  int64
+}
+
+var (
+	// lock for the labelled metrics
+	metricsMutex sync.Mutex
+	// commands received by command name & guild
+	commandsReceived = make(map[commandKey]int64)
+	// failed Discord API requests by status code, "error" if no response was received
+	discordAPIErrors = make(map[string]int64)
+
+	// time taken to generate text
+	generationDuration = newHistogram(generationBuckets)
+	// times the gateway connection was opened since the bot was started
+	gatewayConnects int64
+)
+
+// newHistogram makes a histogram with the given bucket upper bounds in ascending order
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]int64, len(buckets)+1)}
+}
+
+// observe adds a value to the histogram
+func (h *histogram) observe(value float64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	index := sort.SearchFloat64s(h.buckets, value)
+	h.counts[index]++
+	h.sum += value
+	h.count++
+}
+
+// write writes the histogram in the Prometheus text format
+func (h *histogram) write(w io.Writer, name string, help string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+
+	var cumulative int64
+	for index, bound := range h.buckets {
+		cumulative += h.counts[index]
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, formatMetricValue(bound), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", name, formatMetricValue(h.sum))
+	fmt.Fprintf(w, "%s_count %d\n", name, h.count)
+}
+
+// countCommand counts a received command
+func countCommand(command string, guildID string) {
+	metricsMutex.Lock()
+	defer metricsMutex.Unlock()
+
+	commandsReceived[commandKey{Command: command, Guild: guildID}]++
+}
+
+// countAPIError counts a failed Discord API request
+func countAPIError(code string) {
+	metricsMutex.Lock()
+	defer metricsMutex.Unlock()
+
+	discordAPIErrors[code]++
+}
+
+// generateText generates words from a model starting with one of the seed words, counting the words &
+// timing the generation for the statistics. Returns an error if the bot started shutting down while generating
+func generateText(wordModel *WordModel, amount *int, seed []string) (string, error) {
+	start := time.Now()
+	generatedText, err := GenerateWordsContext(botContext, wordModel, amount, seed)
+	if err != nil {
+		return "", err
+	}
+	generationDuration.observe(time.Since(start).Seconds())
+
+	atomic.AddInt64(&wordsGenerated, int64(len(strings.Fields(generatedText))))
+	return generatedText, nil
+}
+
+// gatewayReconnects returns how many times the gateway connection was opened again after the first time
+func gatewayReconnects() int64 {
+	connects := atomic.LoadInt64(&gatewayConnects)
+	if connects < 1 {
+		return 0
+	}
+	return connects - 1
+}
+
+// apiErrorTransport counts the failed requests made through it
+type apiErrorTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip makes the request with the wrapped transport & counts it if it failed
+func (t apiErrorTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	response, err := t.next.RoundTrip(request)
+	if err != nil {
+		countAPIError("error")
+	} else if response.StatusCode >= 400 {
+		countAPIError(strconv.Itoa(response.StatusCode))
+	}
+	return response, err
+}
+
+// formatMetricValue formats a number like Prometheus expects it
+func formatMetricValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// escapeLabelValue escapes a label value for the Prometheus text format
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// writeMetric writes a metric without labels in the Prometheus text format
+func writeMetric(w io.Writer, name string, metricType string, help string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", name, help, name, metricType, name, formatMetricValue(value))
+}
+
+// writeMetrics writes every metric in the Prometheus text format
+func writeMetrics(w io.Writer) {
+	metricsMutex.Lock()
+	commandKeys := make([]commandKey, 0, len(commandsReceived))
+	for key := range commandsReceived {
+		commandKeys = append(commandKeys, key)
+	}
+	sort.Slice(commandKeys, func(a, b int) bool {
+		if commandKeys[a].Command != commandKeys[b].Command {
+			return commandKeys[a].Command < commandKeys[b].Command
+		}
+		return commandKeys[a].Guild < commandKeys[b].Guild
+	})
+
+	fmt.Fprint(w, "# HELP hurabot_commands_received_total Commands received by command & guild.\n"+
+		"# TYPE hurabot_commands_received_total counter\n")
+	for _, key := range commandKeys {
+		fmt.Fprintf(w, "hurabot_commands_received_total{command=\"%s\",guild=\"%s\"} %d\n",
+			escapeLabelValue(key.Command), escapeLabelValue(key.Guild), commandsReceived[key])
+	}
+
+	codes := make([]string, 0, len(discordAPIErrors))
+	for code := range discordAPIErrors {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	fmt.Fprint(w, "# HELP hurabot_discord_api_errors_total Failed Discord API requests by status code.\n"+
+		"# TYPE hurabot_discord_api_errors_total counter\n")
+	for _, code := range codes {
+		fmt.Fprintf(w, "hurabot_discord_api_errors_total{code=\"%s\"} %d\n", escapeLabelValue(code), discordAPIErrors[code])
+	}
+	metricsMutex.Unlock()
+
+	generationDuration.write(w, "hurabot_generation_duration_seconds", "Time taken to generate text.")
+	writeMetric(w, "hurabot_words_generated_total", "counter", "Words generated.", float64(atomic.LoadInt64(&wordsGenerated)))
+	writeMetric(w, "hurabot_gateway_reconnects_total", "counter", "Times the gateway connection was opened again.",
+		float64(gatewayReconnects()))
+
+	modelsMutex.RLock()
+	modelCount := len(botModels)
+	modelsMutex.RUnlock()
+	cachedCount, cachedBytes := wordModelCache.stats()
+
+	writeMetric(w, "hurabot_models", "gauge", "Models found.", float64(modelCount))
+	writeMetric(w, "hurabot_models_loaded", "gauge", "Models loaded in memory.", float64(cachedCount))
+	writeMetric(w, "hurabot_models_loaded_bytes", "gauge", "Estimated memory used by the loaded models.", float64(cachedBytes))
+
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	writeMetric(w, "go_memstats_alloc_bytes", "gauge", "Bytes of allocated heap objects.", float64(memStats.Alloc))
+	writeMetric(w, "go_memstats_sys_bytes", "gauge", "Bytes of memory obtained from the OS.", float64(memStats.Sys))
+	writeMetric(w, "go_goroutines", "gauge", "Number of goroutines.", float64(runtime.NumGoroutine()))
+	writeMetric(w, "process_start_time_seconds", "gauge", "Start time of the bot since the Unix epoch in seconds.",
+		float64(botStartTime.Unix()))
+}
+
+// metricsHandler serves the metrics in the Prometheus text format
+func metricsHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writeMetrics(w)
+}
+
+// startHTTPServer starts serving handler on address in the background, the server has to be closed when the bot stops
+func startHTTPServer(address string, handler http.Handler) (*http.Server, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %v", address, err)
+	}
+
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && errors.Is(err, http.ErrServerClosed) == false {
+			logger.Error("HTTP server stopped", "address", address, "error", err)
+		}
+	}()
+	return server, nil
+}
+
+// startHTTPServers starts serving the metrics & health checks on the addresses in the config.
+// Handlers with the same address share a server
+func startHTTPServers() ([]*http.Server, error) {
+	muxes := make(map[string]*http.ServeMux)
+	addresses := make([]string, 0, 2)
+	handle := func(address string, pattern string, handler http.HandlerFunc) {
+		if address == "" {
+			return
+		}
+		if _, ok := muxes[address]; ok == false {
+			muxes[address] = http.NewServeMux()
+			addresses = append(addresses, address)
+		}
+		muxes[address].HandleFunc(pattern, handler)
+		logger.Info("Serving HTTP endpoint", "address", address, "path", pattern)
+	}
+
+	handle(LoadedConfig.MetricsListenAddress, "/metrics", metricsHandler)
+	handle(LoadedConfig.HealthListenAddress, "/healthz", healthHandler)
+	handle(LoadedConfig.HealthListenAddress, "/readyz", readyHandler)
+
+	servers := make([]*http.Server, 0, len(addresses))
+	for _, address := range addresses {
+		server, err := startHTTPServer(address, muxes[address])
+		if err != nil {
+			for _, started := range servers {
+				started.Close()
+			}
+			return nil, err
+		}
+		servers = append(servers, server)
+	}
+	return servers, nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// setupMetricsTest resets the metrics so the tests don't see each other's counts
+func setupMetricsTest(t *testing.T) {
+	resetMetrics := func() {
+		metricsMutex.Lock()
+		commandsReceived = make(map[commandKey]int64)
+		discordAPIErrors = make(map[string]int64)
+		metricsMutex.Unlock()
+
+		generationDuration = newHistogram(generationBuckets)
+		atomic.StoreInt64(&wordsGenerated, 0)
+		atomic.StoreInt64(&gatewayConnects, 0)
+	}
+
+	resetMetrics()
+	t.Cleanup(resetMetrics)
+}
+
+// scrapeMetrics returns the metrics served by metricsHandler
+func scrapeMetrics(t *testing.T) string {
+	recorder := httptest.NewRecorder()
+	metricsHandler(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain") == false {
+		t.Errorf("unexpected content type %s", recorder.Header().Get("Content-Type"))
+	}
+	return recorder.Body.String()
+}
+
+func TestMetrics(t *testing.T) {
+	setupBotTest(t, &MainBotConfig{MaxWords: 10, DefaultWords: 3}, "word")
+	setupMetricsTest(t)
+
+	s := newFakeSession()
+	interactionHandler(s, commandInteraction("generate-text", stringOption("model", "test"), intOption("words", 2)))
+	interactionHandler(s, commandInteraction("generate-text", stringOption("model", "test")))
+
+	atomic.AddInt64(&gatewayConnects, 3)
+
+	metrics := scrapeMetrics(t)
+
+	for _, want := range []string{
+		"hurabot_commands_received_total{command=\"generate-text\",guild=\"20\"} 2\n",
+		"hurabot_generation_duration_seconds_count 2\n",
+		"hurabot_generation_duration_seconds_bucket{le=\"+Inf\"} 2\n",
+		"hurabot_gateway_reconnects_total 2\n",
+		"hurabot_models 1\n",
+		"# TYPE hurabot_words_generated_total counter\n",
+		"# TYPE go_goroutines gauge\n",
+	} {
+		if strings.Contains(metrics, want) == false {
+			t.Errorf("metrics are missing %q:\n%s", want, metrics)
+		}
+	}
+
+	if atomic.LoadInt64(&wordsGenerated) < 1 {
+		t.Error("generated words were not counted")
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := newHistogram([]float64{1, 5})
+	h.observe(0.5)
+	h.observe(1)
+	h.observe(3)
+	h.observe(10)
+
+	var output strings.Builder
+	h.write(&output, "test_seconds", "Test.")
+
+	want := "# HELP test_seconds Test.\n" +
+		"# TYPE test_seconds histogram\n" +
+		"test_seconds_bucket{le=\"1\"} 2\n" +
+		"test_seconds_bucket{le=\"5\"} 3\n" +
+		"test_seconds_bucket{le=\"+Inf\"} 4\n" +
+		"test_seconds_sum 14.5\n" +
+		"test_seconds_count 4\n"
+	if output.String() != want {
+		t.Errorf("unexpected histogram output:\n%s", output.String())
+	}
+}
+
+func TestAPIErrorTransport(t *testing.T) {
+	setupMetricsTest(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: apiErrorTransport{next: http.DefaultTransport}}
+	for _, requestPath := range []string{"/ok", "/missing", "/missing"} {
+		response, err := client.Get(server.URL + requestPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+	}
+
+	metrics := scrapeMetrics(t)
+	if strings.Contains(metrics, "hurabot_discord_api_errors_total{code=\"404\"} 2\n") == false {
+		t.Errorf("failed requests were not counted:\n%s", metrics)
+	}
+	if strings.Contains(metrics, "code=\"200\"") {
+		t.Errorf("successful requests were counted:\n%s", metrics)
+	}
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	if escaped := escapeLabelValue("a\"b\\c\nd"); escaped != `a\"b\\c\nd` {
+		t.Errorf("unexpected escaped label value %s", escaped)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/csv"
 	"encoding/gob"
 	"encoding/json"
@@ -9,7 +10,6 @@ import (
 	"github.com/dixonwille/skywalker"
 	"github.com/mb-14/gomarkov"
 	"io"
-	"log"
 	"math/rand"
 	"os"
 	"path"
@@ -17,6 +17,8 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
+	"unicode"
 )
 
 // DiscordMessagesChannelInfoFromFile data decoded from channel.json files
@@ -65,14 +67,59 @@ type MessagesCsv struct {
 	Timestamp   string
 	Contents    string
 	Attachments string
+	// ID of the channel the message was sent in, not part of the CSV
+	ChannelID int
+	// ID of the user who sent the message if it's known, not part of the CSV
+	AuthorID string
+}
+
+// ModelMessage a single sanitized message stored in a WordModel
+type ModelMessage struct {
+	// ID of the message
+	ID int
+	// ID of the channel the message was sent in
+	ChannelID int
+	// ID of the user who sent the message, empty if it's not known
+	AuthorID string
+	// Time the message was sent
+	Timestamp time.Time
+	// Sanitized words of the message
+	Words []string
 }
 
 // WordModel containing a list of words
 type WordModel struct {
+	// Stable ID of model, used for choosing the model in configs, commands & Discord
+	ID string
 	// Name of model
 	Name string
+	// Description of model, shown when choosing models in Discord
+	Description string
 	// Slice of words the model contains
 	Words []string
+	// IDs of the channels that were enabled when the model was created
+	Channels []int
+	// Messages the words were taken from, empty for models made before messages were stored
+	Messages []ModelMessage
+	// IDs of the users whose messages are in the model
+	Contributors []string
+
+	// path of the file the model was loaded from
+	filePath string
+	// lock for generating text & modifying the model at the same time
+	mutex sync.Mutex
+}
+
+// ModelInfo the information of a WordModel without its words
+type ModelInfo struct {
+	// ID of model
+	ID string
+	// Name of model
+	Name string
+	// Description of model
+	Description string
+	// IDs of the users whose messages are in the model
+	Contributors []string
 }
 
 // ChannelWorker Worker for reading channel directories in Discord message data
@@ -88,7 +135,7 @@ func (w *ChannelWorker) Work(path string) {
 
 	file, err := os.Stat(path)
 	if err != nil {
-		log.Println("Failed reading file " + path + ": " + err.Error())
+		logger.Error("Failed reading file", "file", path, "error", err)
 	}
 
 	if file.Name() == "channel.json" {
@@ -105,63 +152,21 @@ var ModelFileName string
 // ModelName Name of the model to be created
 var ModelName string
 
-func CreateModel(directory *os.File) error {
-	// try to load config from default location
-	configLoaded := false
+// ModelDescription Description of the model to be created
+var ModelDescription string
 
-	if err := ConfigLoadConfig(nil); err == nil {
-		configLoaded = true
-	}
+// ModelAuthorID ID of the user whose messages the model is made from, found from the data export if not set
+var ModelAuthorID string
 
-	// determine if we have a file or a directory
-
-	fileInfo, err := directory.Stat()
-
-	if err != nil {
-		return fmt.Errorf("failed to get info from %s: %v", directory.Name(), err)
-	}
-
-	if fileInfo.IsDir() == false {
-		return fmt.Errorf("%s is not a directory", directory.Name())
-	}
-
-	// check that directory has the index.json file
-	_, err = os.Stat(path.Join(directory.Name(), "index.json"))
-
-	if err != nil {
-		return fmt.Errorf("failed to stat index.json file from %s: %v", directory.Name(), err)
-	}
-
-	// load the raw channel info
-	channelInfo, err := LoadChannels(directory)
-	if err != nil {
-		return fmt.Errorf("failed to read channels from %s: %v", directory.Name(), err)
-	}
-
-	// open index.json file for decoding direct messages
-	indexFile, err := os.Open(path.Join(directory.Name(), "index.json"))
-	if err != nil {
-		return fmt.Errorf("failed to open index file from %s: %v", directory.Name(), err)
-	}
-
-	dmInfo, err := LoadDirectMessages(indexFile)
-	if err != nil {
-		return fmt.Errorf("failed to read direct messages from %s: %v", directory.Name(), err)
-	}
+func CreateModel(directory *os.File) error {
+	// try to load config from default location
+	_ = ConfigLoadConfig(nil)
 
-	// create the guilds
-	DiscordGuilds, err = CreateGuilds(channelInfo, dmInfo)
+	// load the guilds and their channels from the directory
+	var err error
+	DiscordGuilds, err = LoadDiscordGuilds(directory)
 	if err != nil {
-		return fmt.Errorf("failed to create guilds from channel infos: %v", err)
-	}
-
-	if err := indexFile.Close(); err != nil {
-		log.Printf("Failed to close index file %s: %v", indexFile.Name(), err)
-	}
-
-	// check that some guilds were loaded
-	if len(DiscordGuilds) < 1 {
-		return fmt.Errorf("no guilds found")
+		return err
 	}
 
 	// start CUI for selecting enabled channels
@@ -170,8 +175,9 @@ func CreateModel(directory *os.File) error {
 	// report model name and enabled channels after GUI
 	fmt.Printf("Model filename: %s\n"+
 		"Model name: %s\n"+
+		"Model description: %s\n"+
 		"Enabled channels:\n",
-		ModelFileName, ModelName)
+		ModelFileName, ModelName, ModelDescription)
 
 	for _, guild := range DiscordGuilds {
 		for _, channel := range guild.Channels {
@@ -187,7 +193,8 @@ func CreateModel(directory *os.File) error {
 	resultChar, _, err := reader.ReadRune()
 
 	if err != nil {
-		log.Fatal(err)
+		logger.Error("Failed to read the answer", "error", err)
+		os.Exit(1)
 	}
 
 	if strings.ToLower(string(resultChar)) != "y" {
@@ -197,58 +204,47 @@ func CreateModel(directory *os.File) error {
 	// check model name & modify is necessary
 	if ModelName == "" {
 		ModelName = "model"
-		log.Println("Model name was not set, automatically setting it to " + ModelName)
+		logger.Info("Model name was not set, automatically setting it", "name", ModelName)
 	}
 
 	if ModelFileName == "" {
 		ModelFileName = "model"
-		log.Println("Model filename was not set, automatically setting it to " + ModelFileName)
+		logger.Info("Model filename was not set, automatically setting it", "file", ModelFileName)
 	}
 
 	if strings.HasSuffix(ModelFileName, ".gob") == false {
 		ModelFileName = ModelFileName + ".gob"
 	}
 
-	log.Println("Making model " + ModelName)
-
-	var messagesParsed []MessagesCsv
-
-	// parse the messages.csv files for all enabled channels
-	for _, guild := range DiscordGuilds {
-		for _, channel := range guild.Channels {
-			if channel.Enabled == true {
-				log.Printf("Processing channel %s in guild %s\n", channel.Name, guild.Name)
-
-				// get the filepath of the channel's messages.csv
-				messagesFilePath := path.Join(directory.Name(), fmt.Sprintf("c%d", channel.ID), "messages.csv")
-
-				// open the messages.csv of the channel
-				messagesCsv, err := os.Open(messagesFilePath)
-
-				if err != nil {
-					return fmt.Errorf("failed to open messages.csv file for channel %s: %v", channel.Name, err)
-				}
-
-				parsedMessages, err := ProcessMessagesCSV(messagesCsv)
+	// the bot chooses models by ID, so only one of the models with the same ID could be used
+	saveDirectory, err := DefaultModelDirectory()
+	if err != nil {
+		return err
+	}
 
-				if err != nil {
-					log.Printf("Failed to parse messages from channel %s: %v\n", channel.Name, err)
-					continue
-				}
+	modelID := ModelIDFromFileName(ModelFileName)
+	if otherPath, err := modelIDUsedBy(saveDirectory, modelID, path.Join(saveDirectory, ModelFileName)); err != nil {
+		return err
+	} else if otherPath != "" {
+		return fmt.Errorf("model ID %s is already used by %s, choose another filename", modelID, otherPath)
+	}
 
-				if err := messagesCsv.Close(); err != nil {
-					log.Printf("Failed to close file %s: %v\n", messagesCsv.Name(), err)
-				}
+	logger.Info("Making model", "name", ModelName)
 
-				messagesParsed = append(messagesParsed, parsedMessages...)
+	// parse the messages.csv files for all enabled channels
+	messagesParsed, err := ParseEnabledChannels(directory, DiscordGuilds)
+	if err != nil {
+		return err
+	}
 
-			}
-		}
+	messagesParsed, err = ApplyMessageAuthors(directory, messagesParsed)
+	if err != nil {
+		return err
 	}
 
 	// close the directory file since it's no longer needed
 	if err := directory.Close(); err != nil {
-		log.Printf("Failed to close directory %s: %v\n", directory.Name(), err)
+		logger.Warn("Failed to close directory", "directory", directory.Name(), "error", err)
 	}
 
 	// check if any messages were parsed
@@ -256,30 +252,25 @@ func CreateModel(directory *os.File) error {
 		return fmt.Errorf("no messages were parsed")
 	}
 
-	log.Printf("Parsed %d total messages\n", len(messagesParsed))
+	logger.Info("Parsed messages", "messages", len(messagesParsed))
 
-	log.Println("Now sanitizing messages and splitting words")
-	wordList := SanitizeMessages(messagesParsed)
+	logger.Info("Now sanitizing messages and splitting words")
+	wordModel := &WordModel{
+		ID:          modelID,
+		Name:        ModelName,
+		Description: ModelDescription,
+		Channels:    EnabledChannelIDs(DiscordGuilds),
+		Messages:    CreateModelMessages(messagesParsed),
+	}
+	wordModel.RebuildWords()
 
 	// check that wordList is not empty
-	if len(wordList) < 1 {
+	if len(wordModel.Words) < 1 {
 		return fmt.Errorf("no messages were found")
 	}
 
 	// save model
-	var saveDirectory string
-
-	if configLoaded == true && LoadedConfig.ModelDirectory != "" {
-		saveDirectory = path.Join(LoadedConfig.ModelDirectory)
-	} else {
-		wd, err := os.Executable()
-		if err != nil {
-			return fmt.Errorf("failed to find the executable directory for model saving location: %v", err)
-		}
-		saveDirectory = path.Join(path.Dir(wd), "models")
-	}
-
-	log.Printf("Word processing done, now saving model to %s\n", path.Join(saveDirectory, ModelFileName))
+	logger.Info("Word processing done, now saving model", "file", path.Join(saveDirectory, ModelFileName))
 
 	// check if models folder exists, create if not
 	_, err = os.Stat(saveDirectory)
@@ -292,26 +283,8 @@ func CreateModel(directory *os.File) error {
 		return fmt.Errorf("failed to open models directory at %s: %v", saveDirectory, err)
 	}
 
-	// check if model with same name already exists, create & open model file
-	var fileExists bool
-	var modelFile *os.File
-
-	_, err = os.Stat(path.Join(saveDirectory, ModelFileName))
-
-	if err == nil {
-		fileExists = true
-	} else if os.IsNotExist(err) {
-		fileExists = false
-	}
-
-	if fileExists == false {
-		// file doesn't exist, create
-		modelFile, err = os.OpenFile(path.Join(saveDirectory, ModelFileName), os.O_WRONLY|os.O_CREATE, 0664)
-		if err != nil {
-			return fmt.Errorf("failed to open model file %s for writing: %v", ModelFileName, err)
-		}
-	} else {
-		// file exists, ask user if it's ok to overwrite
+	// check if model with same name already exists & ask user if it's ok to overwrite
+	if _, err = os.Stat(path.Join(saveDirectory, ModelFileName)); err == nil {
 		fmt.Println("Model " + ModelFileName + " already exists, overwrite? y/n")
 		reader = bufio.NewReader(os.Stdin)
 		resultChar, _, err := reader.ReadRune()
@@ -322,10 +295,6 @@ func CreateModel(directory *os.File) error {
 
 		switch strings.ToLower(string(resultChar)) {
 		case "y":
-			modelFile, err = os.OpenFile(path.Join(saveDirectory, ModelFileName), os.O_TRUNC|os.O_WRONLY, 0664)
-			if err != nil {
-				return fmt.Errorf("failed to open model file %s for writing: %v", ModelFileName, err)
-			}
 		case "n":
 			return fmt.Errorf("user aborted model creation")
 		default:
@@ -334,15 +303,247 @@ func CreateModel(directory *os.File) error {
 	}
 
 	// finally encode & save model to file
-	enc := gob.NewEncoder(modelFile)
-	if err := enc.Encode(WordModel{ModelName, wordList}); err != nil {
-		return fmt.Errorf("failed to encode data to model file %s: %v", modelFile.Name(), err)
+	return SaveModel(wordModel, path.Join(saveDirectory, ModelFileName))
+}
+
+// UpdateModel adds the messages from a newer Discord data export to an existing model,
+// using the channels saved in the model and skipping messages the model already has
+func UpdateModel(modelFile *os.File, directory *os.File) error {
+	// try to load config from default location for the backup setting
+	_ = ConfigLoadConfig(nil)
+
+	wordModel, err := LoadModel(modelFile)
+	if err != nil {
+		return fmt.Errorf("failed to load model %s: %v", modelFile.Name(), err)
 	}
 
 	if err := modelFile.Close(); err != nil {
-		log.Printf("Failed to close model file %s: %v\n", modelFile.Name(), err)
+		logger.Warn("Failed to close model file", "file", modelFile.Name(), "error", err)
+	}
+
+	if len(wordModel.Channels) < 1 {
+		return fmt.Errorf("model %s has no saved channels, it has to be created again with model create", wordModel.Name)
+	}
+
+	guilds, err := LoadDiscordGuilds(directory)
+	if err != nil {
+		return err
+	}
+
+	// enable the channels saved in the model
+	modelChannels := make(map[int]bool, len(wordModel.Channels))
+	for _, channelID := range wordModel.Channels {
+		modelChannels[channelID] = true
+	}
+
+	channelsFound := 0
+	for i := range guilds {
+		for j := range guilds[i].Channels {
+			if modelChannels[guilds[i].Channels[j].ID] == true {
+				guilds[i].Channels[j].Enabled = true
+				channelsFound++
+			}
+		}
+	}
+
+	if channelsFound < 1 {
+		return fmt.Errorf("none of the channels of model %s were found in %s", wordModel.Name, directory.Name())
+	}
+
+	logger.Info("Updating model", "model", wordModel.ID, "name", wordModel.Name, "channels_found", channelsFound, "channels", len(wordModel.Channels))
+
+	messagesParsed, err := ParseEnabledChannels(directory, guilds)
+	if err != nil {
+		return err
+	}
+
+	messagesParsed, err = ApplyMessageAuthors(directory, messagesParsed)
+	if err != nil {
+		return err
+	}
+
+	if err := directory.Close(); err != nil {
+		logger.Warn("Failed to close directory", "directory", directory.Name(), "error", err)
+	}
+
+	// only keep the messages that are not in the model yet
+	modelMessages := make(map[int]bool, len(wordModel.Messages))
+	for _, message := range wordModel.Messages {
+		modelMessages[message.ID] = true
+	}
+
+	newMessages := make([]MessagesCsv, 0)
+	for _, message := range messagesParsed {
+		if modelMessages[message.ID] == false {
+			newMessages = append(newMessages, message)
+		}
+	}
+
+	logger.Info("Found new messages", "new_messages", len(newMessages), "messages", len(messagesParsed))
+
+	if len(newMessages) < 1 {
+		fmt.Println("Model " + wordModel.Name + " is already up to date")
+		return nil
+	}
+
+	wordCount := len(wordModel.Words)
+	wordModel.Messages = append(wordModel.Messages, CreateModelMessages(newMessages)...)
+	wordModel.RebuildWords()
+
+	logger.Info("Added words to model, now saving it", "model", wordModel.ID, "words", len(wordModel.Words)-wordCount, "file", modelFile.Name())
+
+	return SaveModel(wordModel, modelFile.Name())
+}
+
+// DescribeModel sets the description of a model file
+func DescribeModel(modelFile *os.File, description string) error {
+	// try to load config from default location for the backup setting
+	_ = ConfigLoadConfig(nil)
+
+	wordModel, err := LoadModel(modelFile)
+	if err != nil {
+		return fmt.Errorf("failed to load model %s: %v", modelFile.Name(), err)
+	}
+
+	if err := modelFile.Close(); err != nil {
+		logger.Warn("Failed to close model file", "file", modelFile.Name(), "error", err)
+	}
+
+	wordModel.Description = description
+
+	return SaveModel(wordModel, modelFile.Name())
+}
+
+// LoadDiscordGuilds loads the guilds and their channels from a Discord messages directory
+func LoadDiscordGuilds(directory *os.File) ([]DiscordGuild, error) {
+	// determine if we have a file or a directory
+	fileInfo, err := directory.Stat()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get info from %s: %v", directory.Name(), err)
 	}
 
+	if fileInfo.IsDir() == false {
+		return nil, fmt.Errorf("%s is not a directory", directory.Name())
+	}
+
+	// check that directory has the index.json file
+	_, err = os.Stat(path.Join(directory.Name(), "index.json"))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat index.json file from %s: %v", directory.Name(), err)
+	}
+
+	// load the raw channel info
+	channelInfo, err := LoadChannels(directory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read channels from %s: %v", directory.Name(), err)
+	}
+
+	// open index.json file for decoding direct messages
+	indexFile, err := os.Open(path.Join(directory.Name(), "index.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open index file from %s: %v", directory.Name(), err)
+	}
+
+	dmInfo, err := LoadDirectMessages(indexFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read direct messages from %s: %v", directory.Name(), err)
+	}
+
+	if err := indexFile.Close(); err != nil {
+		logger.Warn("Failed to close index file", "file", indexFile.Name(), "error", err)
+	}
+
+	// create the guilds
+	guilds, err := CreateGuilds(channelInfo, dmInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create guilds from channel infos: %v", err)
+	}
+
+	// check that some guilds were loaded
+	if len(guilds) < 1 {
+		return nil, fmt.Errorf("no guilds found")
+	}
+
+	return guilds, nil
+}
+
+// ParseEnabledChannels parses the messages.csv files of all enabled channels in a Discord messages directory
+func ParseEnabledChannels(directory *os.File, guilds []DiscordGuild) ([]MessagesCsv, error) {
+	var messagesParsed []MessagesCsv
+
+	for _, guild := range guilds {
+		for _, channel := range guild.Channels {
+			if channel.Enabled == true {
+				logger.Info("Processing channel", "channel", channel.Name, "guild", guild.Name)
+
+				// get the filepath of the channel's messages.csv
+				messagesFilePath := path.Join(directory.Name(), fmt.Sprintf("c%d", channel.ID), "messages.csv")
+
+				// open the messages.csv of the channel
+				messagesCsv, err := os.Open(messagesFilePath)
+
+				if err != nil {
+					return nil, fmt.Errorf("failed to open messages.csv file for channel %s: %v", channel.Name, err)
+				}
+
+				parsedMessages, err := ProcessMessagesCSV(messagesCsv)
+
+				if err != nil {
+					logger.Error("Failed to parse messages from channel", "channel", channel.Name, "error", err)
+					continue
+				}
+
+				if err := messagesCsv.Close(); err != nil {
+					logger.Warn("Failed to close file", "file", messagesCsv.Name(), "error", err)
+				}
+
+				for i := range parsedMessages {
+					parsedMessages[i].ChannelID = channel.ID
+				}
+
+				messagesParsed = append(messagesParsed, parsedMessages...)
+			}
+		}
+	}
+
+	return messagesParsed, nil
+}
+
+// EnabledChannelIDs returns the IDs of all enabled channels
+func EnabledChannelIDs(guilds []DiscordGuild) []int {
+	channelIDs := make([]int, 0)
+
+	for _, guild := range guilds {
+		for _, channel := range guild.Channels {
+			if channel.Enabled == true {
+				channelIDs = append(channelIDs, channel.ID)
+			}
+		}
+	}
+	return channelIDs
+}
+
+// SaveModel encodes a WordModel to a file, replacing the file only after the model is written completely
+func SaveModel(wordModel *WordModel, modelPath string) error {
+	err := WriteFileAtomic(modelPath, 0664, keepBackups(), func(w io.Writer) error {
+		wordModel.mutex.Lock()
+		defer wordModel.mutex.Unlock()
+
+		enc := gob.NewEncoder(w)
+		if err := enc.Encode(wordModel); err != nil {
+			return fmt.Errorf("failed to encode data to model file %s: %v", modelPath, err)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	wordModel.filePath = modelPath
+
 	return nil
 }
 
@@ -369,7 +570,7 @@ func LoadChannels(directory *os.File) ([]DiscordMessagesChannelInfoFromFile, err
 	for _, cf := range cw.found {
 		file, err := os.Open(cf)
 		if err != nil {
-			log.Printf("Failed to open file %s: %v\n", cf, err)
+			logger.Error("Failed to open file", "file", cf, "error", err)
 			continue
 		}
 
@@ -378,7 +579,7 @@ func LoadChannels(directory *os.File) ([]DiscordMessagesChannelInfoFromFile, err
 		dec := json.NewDecoder(file)
 
 		if err = dec.Decode(&newChannel); err != nil {
-			log.Printf("Failed to decode file %s: %v\n", file.Name(), err)
+			logger.Error("Failed to decode file", "file", file.Name(), "error", err)
 		}
 
 		if newChannel.Name != "" {
@@ -386,7 +587,7 @@ func LoadChannels(directory *os.File) ([]DiscordMessagesChannelInfoFromFile, err
 		}
 
 		if err := file.Close(); err != nil {
-			log.Printf("Failed to close file %s: %v\n", file.Name(), err)
+			logger.Warn("Failed to close file", "file", file.Name(), "error", err)
 		}
 	}
 
@@ -579,49 +780,167 @@ func SanitizeMessages(messages []MessagesCsv) []string {
 
 	// loop through all messages, separate into words
 	for _, message := range messages {
-		messageWords := strings.Split(message.Contents, " ")
+		wordList = append(wordList, SanitizeMessage(message.Contents)...)
+	}
+	return wordList
+}
 
-		for _, word := range messageWords {
+// SanitizeMessage splits the contents of a message into words, skipping the ones that shouldn't be in a model
+func SanitizeMessage(contents string) []string {
+	var wordList []string
 
-			// word is empty, skip
-			if word == "" {
-				log.Println("Word is empty, skipping")
-				continue
-			}
+	messageWords := strings.Split(contents, " ")
 
-			// check if word is a URL, skip if it is
-			if strings.HasPrefix(word, "https://") || strings.HasPrefix(word, "http://") {
-				log.Println("Word " + word + " is a URL, skipping")
-				continue
-			}
+	for _, word := range messageWords {
 
-			// check if word has an animated emoji, then skip
-			if strings.HasPrefix(word, "<a:") && strings.HasSuffix(word, ">") {
-				log.Println("Word " + word + " is an animated emoji, skipping")
-				continue
-			}
+		// word is empty, skip
+		if word == "" {
+			logger.Debug("Word is empty, skipping")
+			continue
+		}
 
-			// word is a mention, skip
-			if strings.Contains(word, "<@") && strings.HasSuffix(word, ">") {
-				log.Println("Word " + word + " is a mention, skipping")
-				continue
-			}
+		// check if word is a URL, skip if it is
+		if strings.HasPrefix(word, "https://") || strings.HasPrefix(word, "http://") {
+			logger.Debug("Word is a URL, skipping", "word", word)
+			continue
+		}
 
-			// word is a channel mention, skip
-			if strings.HasPrefix(word, "<#") && strings.HasSuffix(word, ">") {
-				log.Println("Word " + word + " is a channel mention, skipping")
-				continue
-			}
+		// check if word has an animated emoji, then skip
+		if strings.HasPrefix(word, "<a:") && strings.HasSuffix(word, ">") {
+			logger.Debug("Word is an animated emoji, skipping", "word", word)
+			continue
+		}
 
-			// turn word to lowercase
-			word = strings.ToLower(word)
+		// word is a mention, skip
+		if strings.Contains(word, "<@") && strings.HasSuffix(word, ">") {
+			logger.Debug("Word is a mention, skipping", "word", word)
+			continue
+		}
 
-			wordList = append(wordList, word)
+		// word is a channel mention, skip
+		if strings.HasPrefix(word, "<#") && strings.HasSuffix(word, ">") {
+			logger.Debug("Word is a channel mention, skipping", "word", word)
+			continue
 		}
+
+		// turn word to lowercase
+		word = strings.ToLower(word)
+
+		wordList = append(wordList, word)
 	}
 	return wordList
 }
 
+// CreateModelMessages sanitizes parsed messages into ModelMessages, leaving out messages without any words
+func CreateModelMessages(messages []MessagesCsv) []ModelMessage {
+	modelMessages := make([]ModelMessage, 0, len(messages))
+
+	for _, message := range messages {
+		words := SanitizeMessage(message.Contents)
+		if len(words) < 1 {
+			continue
+		}
+
+		timestamp, err := ParseMessageTimestamp(message.Timestamp)
+		if err != nil {
+			logger.Warn("Failed to parse timestamp of message", "message", message.ID, "error", err)
+		}
+
+		modelMessages = append(modelMessages, ModelMessage{
+			ID:        message.ID,
+			ChannelID: message.ChannelID,
+			AuthorID:  message.AuthorID,
+			Timestamp: timestamp,
+			Words:     words,
+		})
+	}
+	return modelMessages
+}
+
+// ParseMessageTimestamp parses a timestamp from a messages.csv file
+func ParseMessageTimestamp(timestamp string) (time.Time, error) {
+	parsed, err := time.Parse("2006-01-02 15:04:05.999999-07:00", timestamp)
+	if err != nil {
+		// newer data exports use RFC 3339 timestamps
+		return time.Parse(time.RFC3339Nano, timestamp)
+	}
+	return parsed, nil
+}
+
+// Copy returns a copy of a WordModel that can be modified without changing the original
+func (wordModel *WordModel) Copy() *WordModel {
+	wordModel.mutex.Lock()
+	defer wordModel.mutex.Unlock()
+
+	return &WordModel{
+		ID:           wordModel.ID,
+		Name:         wordModel.Name,
+		Description:  wordModel.Description,
+		Words:        append([]string(nil), wordModel.Words...),
+		Channels:     append([]int(nil), wordModel.Channels...),
+		Messages:     append([]ModelMessage(nil), wordModel.Messages...),
+		Contributors: append([]string(nil), wordModel.Contributors...),
+		filePath:     wordModel.filePath,
+	}
+}
+
+// RebuildWords replaces the words & contributors of a WordModel with the ones of its messages,
+// models made before messages were stored are left as is
+func (wordModel *WordModel) RebuildWords() {
+	if len(wordModel.Channels) < 1 && len(wordModel.Messages) < 1 {
+		return
+	}
+
+	words := make([]string, 0, len(wordModel.Words))
+	for _, message := range wordModel.Messages {
+		words = append(words, message.Words...)
+	}
+	wordModel.Words = words
+	wordModel.Contributors = modelContributors(wordModel.Messages)
+}
+
+// AddMessage adds a message & its words to a WordModel
+func (wordModel *WordModel) AddMessage(message ModelMessage) {
+	wordModel.mutex.Lock()
+	defer wordModel.mutex.Unlock()
+
+	wordModel.Messages = append(wordModel.Messages, message)
+	wordModel.Words = append(wordModel.Words, message.Words...)
+
+	if message.AuthorID == "" {
+		return
+	}
+
+	// keep the contributors sorted
+	index := sort.SearchStrings(wordModel.Contributors, message.AuthorID)
+	if index < len(wordModel.Contributors) && wordModel.Contributors[index] == message.AuthorID {
+		return
+	}
+	wordModel.Contributors = append(wordModel.Contributors, "")
+	copy(wordModel.Contributors[index+1:], wordModel.Contributors[index:])
+	wordModel.Contributors[index] = message.AuthorID
+}
+
+// RemoveAuthors removes the messages of the authors & their words from a WordModel, returns the amount of messages removed
+func (wordModel *WordModel) RemoveAuthors(authorIDs []string) int {
+	wordModel.mutex.Lock()
+	defer wordModel.mutex.Unlock()
+
+	messages := make([]ModelMessage, 0, len(wordModel.Messages))
+	for _, message := range wordModel.Messages {
+		if containsID(authorIDs, message.AuthorID) == false {
+			messages = append(messages, message)
+		}
+	}
+
+	removed := len(wordModel.Messages) - len(messages)
+	if removed > 0 {
+		wordModel.Messages = messages
+		wordModel.RebuildWords()
+	}
+	return removed
+}
+
 // LoadModel loads a WordModel from os.File
 func LoadModel(modelFile *os.File) (*WordModel, error) {
 	var wordModel *WordModel
@@ -633,11 +952,192 @@ func LoadModel(modelFile *os.File) (*WordModel, error) {
 		return nil, err
 	}
 
+	wordModel.filePath = modelFile.Name()
+
+	// models made before IDs were stored get their ID from the filename
+	if wordModel.ID == "" {
+		wordModel.ID = ModelIDFromFileName(modelFile.Name())
+	}
+
 	return wordModel, nil
 }
 
+// LoadModelInfo loads the ModelInfo of a WordModel from os.File without keeping the words of the model in memory
+func LoadModelInfo(modelFile *os.File) (*ModelInfo, error) {
+	var modelInfo *ModelInfo
+
+	// gob skips the fields ModelInfo doesn't have
+	dec := gob.NewDecoder(modelFile)
+	if err := dec.Decode(&modelInfo); err != nil {
+		return nil, err
+	}
+
+	if modelInfo.ID == "" {
+		modelInfo.ID = ModelIDFromFileName(modelFile.Name())
+	}
+
+	return modelInfo, nil
+}
+
+// ModelIDFromFileName makes a model ID from the filename of a model
+func ModelIDFromFileName(fileName string) string {
+	return Slugify(strings.TrimSuffix(path.Base(fileName), ".gob"))
+}
+
+// modelIDUsedBy returns the file of another model in a directory with the ID, empty if no other model has it.
+// modelPath is the file of the model itself, which can have the ID
+func modelIDUsedBy(directory string, id string, modelPath string) (string, error) {
+	entries, err := os.ReadDir(directory)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to read models directory %s: %v", directory, err)
+	}
+
+	for _, entry := range entries {
+		otherPath := path.Join(directory, entry.Name())
+		if entry.IsDir() || strings.HasSuffix(entry.Name(), ".gob") == false || otherPath == path.Clean(modelPath) {
+			continue
+		}
+
+		// models that can't be loaded aren't used by the bot either
+		modelInfo, err := loadModelInfoFile(otherPath)
+		if err != nil {
+			logger.Warn("Failed to load model info", "file", otherPath, "error", err)
+			continue
+		}
+
+		if modelInfo.ID == id {
+			return otherPath, nil
+		}
+	}
+	return "", nil
+}
+
+// Slugify turns a string to lowercase letters, numbers & dashes
+func Slugify(text string) string {
+	var slug strings.Builder
+	dash := false
+
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && slug.Len() > 0 {
+				slug.WriteRune('-')
+			}
+			slug.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+
+	if slug.Len() == 0 {
+		return "model"
+	}
+	return slug.String()
+}
+
+// FindModelByID finds the file of the model with an ID from a directory
+func FindModelByID(directory string, id string) (string, error) {
+	directoryContents, err := os.ReadDir(directory)
+	if err != nil {
+		return "", fmt.Errorf("failed to read model directory %s: %v", directory, err)
+	}
+
+	for _, file := range directoryContents {
+		if file.IsDir() || strings.HasSuffix(file.Name(), ".gob") == false {
+			continue
+		}
+
+		modelFile, err := os.Open(path.Join(directory, file.Name()))
+		if err != nil {
+			logger.Error("Failed to open model file", "file", file.Name(), "error", err)
+			continue
+		}
+
+		modelInfo, err := LoadModelInfo(modelFile)
+
+		if err := modelFile.Close(); err != nil {
+			logger.Warn("Failed to close model file", "file", modelFile.Name(), "error", err)
+		}
+
+		if err != nil {
+			logger.Error("Failed to load model file", "file", modelFile.Name(), "error", err)
+			continue
+		}
+
+		if modelInfo.ID == id {
+			return modelFile.Name(), nil
+		}
+	}
+
+	return "", fmt.Errorf("no model with ID %s found in %s", id, directory)
+}
+
+// OpenModelFile opens a model file by its path, or by its ID from the model directory
+func OpenModelFile(pathOrID string) (*os.File, error) {
+	if modelFile, err := os.Open(pathOrID); err == nil {
+		return modelFile, nil
+	}
+
+	modelDirectory, err := DefaultModelDirectory()
+	if err != nil {
+		return nil, err
+	}
+
+	modelPath, err := FindModelByID(modelDirectory, pathOrID)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(modelPath)
+}
+
+// DefaultModelDirectory returns the model directory from the config, or the models directory next to the executable
+func DefaultModelDirectory() (string, error) {
+	if LoadedConfig == nil {
+		// try to load config from default location
+		_ = ConfigLoadConfig(nil)
+	}
+
+	if LoadedConfig != nil && LoadedConfig.ModelDirectory != "" {
+		return path.Join(LoadedConfig.ModelDirectory), nil
+	}
+
+	ed, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the executable directory for the model directory: %v", err)
+	}
+	return path.Join(path.Dir(ed), "models"), nil
+}
+
 // GenerateWords generates random words from a WordModel
 func GenerateWords(model *WordModel, amount *int) string {
+	return GenerateWordsFrom(model, amount, nil)
+}
+
+// GenerateWordsFrom generates random words from a WordModel starting with one of the seed words that are in the model.
+// The words are generated like with GenerateWords if none of the seed words are found
+func GenerateWordsFrom(model *WordModel, amount *int, seed []string) string {
+	generatedText, _ := GenerateWordsContext(context.Background(), model, amount, seed)
+	return generatedText
+}
+
+// GenerateWordsContext generates words like GenerateWordsFrom, stopping with the error of ctx if it's cancelled
+func GenerateWordsContext(ctx context.Context, model *WordModel, amount *int, seed []string) (string, error) {
+	model.mutex.Lock()
+	defer model.mutex.Unlock()
+
+	// ctx can be cancelled while waiting for another generation
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	// models that learn from new messages start empty
+	if len(model.Words) < 1 {
+		return "", nil
+	}
+
 	// shuffle the first word for more randomness
 	randomPosition := rand.Intn(len(model.Words))
 	firstWord := model.Words[0]
@@ -652,17 +1152,34 @@ func GenerateWords(model *WordModel, amount *int) string {
 	// insert words to chain
 	chain.Add(model.Words)
 
-	tokens := make([]string, 0, *amount)
+	// building the chain of a big model takes a while
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	tokens := make([]string, 0, *amount+1)
 	tokens = append(tokens, gomarkov.StartToken)
+
+	// start from a random seed word the chain can continue from
+	for _, index := range rand.Perm(len(seed)) {
+		if _, err := chain.Generate(seed[index : index+1]); err == nil {
+			tokens = append(tokens, seed[index])
+			break
+		}
+	}
+
 	for tokens[len(tokens)-1] != gomarkov.EndToken {
 		if len(tokens) >= *amount+1 {
 			break
 		}
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
 		next, _ := chain.Generate(tokens[(len(tokens) - 1):])
 		tokens = append(tokens, next)
 	}
 
 	generatedText := strings.Join(tokens, " ")
 	_, generatedText, _ = strings.Cut(generatedText, "$ ")
-	return generatedText
+	return generatedText, nil
 }
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+)
+
+// modelCache keeps the most recently used models in memory within a memory budget
+type modelCache struct {
+	mutex sync.Mutex
+	// memory budget in bytes, 0 for no limit
+	budget int64
+	// estimated memory used by the cached models
+	used int64
+	// cached models, most recently used first
+	entries *list.List
+	// elements of entries by model file path
+	elements map[string]*list.Element
+}
+
+// modelCacheEntry a model in the cache
+type modelCacheEntry struct {
+	// path of the model file
+	path string
+	// the loaded model
+	model *WordModel
+	// estimated memory used by the model
+	size int64
+}
+
+// newModelCache creates a modelCache with a memory budget in bytes, 0 for no limit
+func newModelCache(budget int64) *modelCache {
+	return &modelCache{
+		budget:   budget,
+		entries:  list.New(),
+		elements: make(map[string]*list.Element),
+	}
+}
+
+// get returns a model from the cache, loading it with load if it's not cached.
+// Least recently used models are evicted if the cache goes over its budget
+func (c *modelCache) get(path string, load func() (*WordModel, error)) (*WordModel, error) {
+	c.mutex.Lock()
+	if element, ok := c.elements[path]; ok {
+		c.entries.MoveToFront(element)
+		c.mutex.Unlock()
+		return element.Value.(*modelCacheEntry).model, nil
+	}
+	c.mutex.Unlock()
+
+	// load without holding the lock so other models can be used meanwhile
+	wordModel, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// the model might have been loaded by someone else at the same time
+	if element, ok := c.elements[path]; ok {
+		c.entries.MoveToFront(element)
+		return element.Value.(*modelCacheEntry).model, nil
+	}
+
+	entry := &modelCacheEntry{path: path, model: wordModel, size: estimateModelSize(wordModel)}
+	c.elements[path] = c.entries.PushFront(entry)
+	c.used += entry.size
+
+	c.evict()
+
+	return wordModel, nil
+}
+
+// evict removes least recently used models until the cache is within its budget, the newest model is always kept
+func (c *modelCache) evict() {
+	if c.budget <= 0 {
+		return
+	}
+
+	for c.used > c.budget && c.entries.Len() > 1 {
+		c.removeElement(c.entries.Back())
+	}
+}
+
+// remove removes a model from the cache, used when its file has changed
+func (c *modelCache) remove(path string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.elements[path]; ok {
+		c.removeElement(element)
+	}
+}
+
+// removeElement removes an element from the cache, the mutex has to be locked
+func (c *modelCache) removeElement(element *list.Element) {
+	entry := element.Value.(*modelCacheEntry)
+	c.entries.Remove(element)
+	delete(c.elements, entry.path)
+	c.used -= entry.size
+}
+
+// stats returns the amount of cached models & their estimated memory use in bytes
+func (c *modelCache) stats() (int, int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.entries.Len(), c.used
+}
+
+// estimateModelSize estimates the memory used by a model in bytes
+func estimateModelSize(wordModel *WordModel) int64 {
+	// string headers are 16 bytes, slice headers 24 bytes
+	size := int64(len(wordModel.Name) + 16)
+
+	for _, word := range wordModel.Words {
+		size += int64(len(word) + 16)
+	}
+
+	size += int64(len(wordModel.Channels) * 8)
+
+	for _, contributor := range wordModel.Contributors {
+		size += int64(len(contributor) + 16)
+	}
+
+	for _, message := range wordModel.Messages {
+		// ID, channel ID, timestamp, author ID & the words slice
+		size += int64(8 + 8 + 24 + len(message.AuthorID) + 16 + 24)
+		for _, word := range message.Words {
+			size += int64(len(word) + 16)
+		}
+	}
+
+	return size
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestModelCache(t *testing.T) {
+	testModels := map[string]*WordModel{
+		"a.gob": {Name: "a", Words: []string{"one", "model"}},
+		"b.gob": {Name: "b", Words: []string{"two", "model"}},
+		"c.gob": {Name: "c", Words: []string{"six", "model"}},
+	}
+
+	loads := 0
+	loader := func(path string) func() (*WordModel, error) {
+		return func() (*WordModel, error) {
+			loads++
+			return testModels[path], nil
+		}
+	}
+
+	// room for two of the models
+	cache := newModelCache(estimateModelSize(testModels["a.gob"]) * 2)
+
+	for _, path := range []string{"a.gob", "b.gob", "a.gob", "c.gob"} {
+		wordModel, err := cache.get(path, loader(path))
+		if err != nil {
+			t.Fatalf("failed to get model %s: %v", path, err)
+		}
+		if wordModel != testModels[path] {
+			t.Errorf("got model %s instead of %s", wordModel.Name, testModels[path].Name)
+		}
+	}
+
+	if loads != 3 {
+		t.Errorf("models were loaded %d times instead of 3", loads)
+	}
+
+	// b was used least recently so it should have been evicted
+	count, _ := cache.stats()
+	if count != 2 {
+		t.Errorf("cache has %d models instead of 2", count)
+	}
+
+	if _, ok := cache.elements["b.gob"]; ok {
+		t.Errorf("least recently used model wasn't evicted")
+	}
+
+	cache.remove("a.gob")
+
+	if count, used := cache.stats(); count != 1 || used != estimateModelSize(testModels["c.gob"]) {
+		t.Errorf("cache has %d models using %d bytes after removing a model", count, used)
+	}
+}
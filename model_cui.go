@@ -66,6 +66,14 @@ func DiscordChannelSelectionCUI() {
 	if err := g.SetKeybinding("modelName", gocui.KeyCtrlD, gocui.ModNone, closeSaveNameConfirm); err != nil {
 		log.Panicln(err)
 	}
+	// keybinding for confirming the model description
+	if err := g.SetKeybinding("modelDescription", gocui.KeyEnter, gocui.ModNone, confirmDescription); err != nil {
+		log.Panicln(err)
+	}
+	// keybinding for closing the model description box
+	if err := g.SetKeybinding("modelDescription", gocui.KeyCtrlD, gocui.ModNone, closeSaveDescriptionConfirm); err != nil {
+		log.Panicln(err)
+	}
 	// keybinding for confirming the model filename
 	if err := g.SetKeybinding("modelFileName", gocui.KeyEnter, gocui.ModNone, confirmFileName); err != nil {
 		log.Panicln(err)
@@ -370,6 +378,53 @@ func confirmName(g *gocui.Gui, v *gocui.View) error {
 	ModelName = nameContent
 
 	closeSaveNameConfirm(g, v)
+	saveDescription(g, v)
+	return nil
+}
+
+// Function for opening the model description box
+func saveDescription(g *gocui.Gui, _ *gocui.View) error {
+	maxX, maxY := g.Size()
+
+	if v, err := g.SetView("modelDescription", maxX/2-30, maxY/2, maxX/2+30, maxY/2+2); err != nil {
+		if err != gocui.ErrUnknownView {
+			return err
+		}
+
+		v.Title = "Enter description for model (Ctrl+D to cancel)"
+		v.Editable = true
+
+		if _, err := g.SetCurrentView("modelDescription"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Function for closing the model description box
+func closeSaveDescriptionConfirm(g *gocui.Gui, _ *gocui.View) error {
+	if err := g.DeleteView("modelDescription"); err != nil {
+		return err
+	}
+	if _, err := g.SetCurrentView("guilds"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Function for confirming the description of the model
+func confirmDescription(g *gocui.Gui, v *gocui.View) error {
+	var descriptionContent string
+	var err error
+	_, cy := v.Cursor()
+
+	if descriptionContent, err = v.Line(cy); err != nil {
+		descriptionContent = ""
+	}
+
+	ModelDescription = descriptionContent
+
+	closeSaveDescriptionConfirm(g, v)
 	saveFileName(g, v)
 	return nil
 }
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// PruneOptions what to remove from a WordModel
+type PruneOptions struct {
+	// Words or phrases to remove from the messages
+	Phrases []string
+	// Regular expressions for words to remove from the messages
+	Patterns []*regexp.Regexp
+	// Remove the messages sent in these channels
+	ChannelIDs []int
+	// Remove the messages sent at or after this time, ignored if zero
+	From time.Time
+	// Remove the messages sent before this time, ignored if zero
+	Until time.Time
+}
+
+// PruneResult how much was removed from a WordModel
+type PruneResult struct {
+	// Amount of messages removed
+	Messages int
+	// Amount of words removed
+	Words int
+}
+
+// removesMessages checks if the options remove whole messages
+func (options PruneOptions) removesMessages() bool {
+	return len(options.ChannelIDs) > 0 || options.From.IsZero() == false || options.Until.IsZero() == false
+}
+
+// matchesMessage checks if a message should be removed, the channel and date options all have to match
+func (options PruneOptions) matchesMessage(message ModelMessage) bool {
+	if len(options.ChannelIDs) > 0 {
+		channelMatches := false
+		for _, channelID := range options.ChannelIDs {
+			if message.ChannelID == channelID {
+				channelMatches = true
+				break
+			}
+		}
+		if channelMatches == false {
+			return false
+		}
+	}
+
+	if options.From.IsZero() == false && message.Timestamp.Before(options.From) {
+		return false
+	}
+
+	if options.Until.IsZero() == false && message.Timestamp.Before(options.Until) == false {
+		return false
+	}
+
+	return true
+}
+
+// pruneWords removes the phrases and words matching the patterns from a slice of words
+func (options PruneOptions) pruneWords(words []string) []string {
+	for _, phrase := range options.Phrases {
+		words = removePhrase(words, SanitizeMessage(phrase))
+	}
+
+	if len(options.Patterns) < 1 {
+		return words
+	}
+
+	prunedWords := make([]string, 0, len(words))
+	for _, word := range words {
+		matches := false
+		for _, pattern := range options.Patterns {
+			if pattern.MatchString(word) {
+				matches = true
+				break
+			}
+		}
+		if matches == false {
+			prunedWords = append(prunedWords, word)
+		}
+	}
+	return prunedWords
+}
+
+// removePhrase removes every occurrence of a sequence of words from a slice of words
+func removePhrase(words []string, phrase []string) []string {
+	if len(phrase) < 1 {
+		return words
+	}
+
+	result := make([]string, 0, len(words))
+
+	for i := 0; i < len(words); i++ {
+		if i+len(phrase) <= len(words) {
+			matches := true
+			for j := range phrase {
+				if words[i+j] != phrase[j] {
+					matches = false
+					break
+				}
+			}
+			if matches == true {
+				i += len(phrase) - 1
+				continue
+			}
+		}
+		result = append(result, words[i])
+	}
+	return result
+}
+
+// PruneModel removes messages and words from a WordModel
+func PruneModel(wordModel *WordModel, options PruneOptions) (PruneResult, error) {
+	wordModel.mutex.Lock()
+	defer wordModel.mutex.Unlock()
+
+	result := PruneResult{}
+	wordCount := len(wordModel.Words)
+
+	// models made before messages were stored only have their words
+	if len(wordModel.Channels) < 1 && len(wordModel.Messages) < 1 {
+		if options.removesMessages() {
+			return result, fmt.Errorf("model %s has no stored messages, channels and dates can't be pruned", wordModel.Name)
+		}
+
+		wordModel.Words = options.pruneWords(wordModel.Words)
+		result.Words = wordCount - len(wordModel.Words)
+		return result, nil
+	}
+
+	messages := make([]ModelMessage, 0, len(wordModel.Messages))
+
+	for _, message := range wordModel.Messages {
+		if options.removesMessages() && options.matchesMessage(message) {
+			continue
+		}
+
+		message.Words = options.pruneWords(message.Words)
+		if len(message.Words) < 1 {
+			continue
+		}
+
+		messages = append(messages, message)
+	}
+
+	result.Messages = len(wordModel.Messages) - len(messages)
+	wordModel.Messages = messages
+	wordModel.RebuildWords()
+	result.Words = wordCount - len(wordModel.Words)
+
+	return result, nil
+}
+
+// ParsePruneDate parses a date given for pruning in the format YYYY-MM-DD
+func ParsePruneDate(date string) (time.Time, error) {
+	return time.Parse("2006-01-02", date)
+}
+
+// PruneModelFile removes messages and words from a model file after asking the user
+func PruneModelFile(modelFile *os.File, options PruneOptions) error {
+	// try to load config from default location for the backup setting
+	_ = ConfigLoadConfig(nil)
+
+	wordModel, err := LoadModel(modelFile)
+	if err != nil {
+		return fmt.Errorf("failed to load model %s: %v", modelFile.Name(), err)
+	}
+
+	if err := modelFile.Close(); err != nil {
+		fmt.Printf("Failed to close model file %s: %v\n", modelFile.Name(), err)
+	}
+
+	result, err := PruneModel(wordModel, options)
+	if err != nil {
+		return err
+	}
+
+	if result.Words == 0 && result.Messages == 0 {
+		fmt.Println("Nothing to remove from model " + wordModel.Name)
+		return nil
+	}
+
+	if len(wordModel.Words) < 1 {
+		return fmt.Errorf("no words would be left in model %s", wordModel.Name)
+	}
+
+	fmt.Printf("Removing %d messages and %d words from model %s, %d words will be left. Continue? y/n\n",
+		result.Messages, result.Words, wordModel.Name, len(wordModel.Words))
+	reader := bufio.NewReader(os.Stdin)
+	resultChar, _, err := reader.ReadRune()
+
+	if err != nil {
+		return err
+	}
+
+	if strings.ToLower(string(resultChar)) != "y" {
+		return fmt.Errorf("aborted by user")
+	}
+
+	if err := SaveModel(wordModel, modelFile.Name()); err != nil {
+		return err
+	}
+
+	fmt.Println("Pruned model " + modelFile.Name())
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestPruneModel(t *testing.T) {
+	testModel := &WordModel{
+		Name:     "Test model",
+		Channels: []int{1, 2},
+		Messages: []ModelMessage{
+			{ID: 1, ChannelID: 1, Timestamp: time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC), Words: []string{"i", "regret", "this", "message"}},
+			{ID: 2, ChannelID: 1, Timestamp: time.Date(2000, 1, 2, 12, 0, 0, 0, time.UTC), Words: []string{"secret123", "stuff"}},
+			{ID: 3, ChannelID: 2, Timestamp: time.Date(2000, 1, 3, 12, 0, 0, 0, time.UTC), Words: []string{"private", "channel"}},
+			{ID: 4, ChannelID: 1, Timestamp: time.Date(2000, 2, 1, 12, 0, 0, 0, time.UTC), Words: []string{"regret", "this"}},
+		},
+	}
+	testModel.RebuildWords()
+
+	result, err := PruneModel(testModel, PruneOptions{
+		Phrases:  []string{"Regret this"},
+		Patterns: []*regexp.Regexp{regexp.MustCompile(`^secret\d+$`)},
+	})
+
+	if err != nil {
+		t.Fatalf("failed to prune words: %v", err)
+	}
+
+	if result.Words != 5 || result.Messages != 1 || len(testModel.Words) != 5 {
+		t.Errorf("pruning words removed %d words and %d messages leaving %d words instead of 5, 1 and 5",
+			result.Words, result.Messages, len(testModel.Words))
+	}
+
+	result, err = PruneModel(testModel, PruneOptions{
+		ChannelIDs: []int{1},
+		From:       time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		Until:      time.Date(2000, 1, 5, 0, 0, 0, 0, time.UTC),
+	})
+
+	if err != nil {
+		t.Fatalf("failed to prune messages: %v", err)
+	}
+
+	if result.Messages != 2 || len(testModel.Messages) != 1 || testModel.Messages[0].ChannelID != 2 {
+		t.Errorf("pruning messages removed %d messages leaving %d instead of 2 and 1", result.Messages, len(testModel.Messages))
+	}
+
+	legacyModel := &WordModel{
+		Name:  "Legacy model",
+		Words: []string{"old", "model", "words"},
+	}
+
+	if _, err := PruneModel(legacyModel, PruneOptions{ChannelIDs: []int{1}}); err == nil {
+		t.Errorf("pruning channels from a model without messages didn't fail")
+	}
+}
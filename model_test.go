@@ -1,12 +1,14 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"math/rand"
 	"os"
 	"path"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -281,3 +283,228 @@ func TestSanitizeMessages(t *testing.T) {
 		t.Logf("failed to remove test file %s: %v", testFile.Name(), err)
 	}
 }
+
+func TestUpdateModel(t *testing.T) {
+	testDir, err := os.MkdirTemp(os.TempDir(), "hurabotTestUpdateModel")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// make a data export with one channel
+	channelDir := path.Join(testDir, "c1")
+	if err := os.Mkdir(channelDir, 0770); err != nil {
+		t.Fatal(err)
+	}
+
+	channelJsonFile, err := os.Create(path.Join(channelDir, "channel.json"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enc := json.NewEncoder(channelJsonFile)
+	if err := enc.Encode(DiscordMessagesChannelInfoFromFile{
+		ID:   "1",
+		Type: 0,
+		Name: "Test channel",
+		Guild: DiscordMessagesChannelGuildInfoFromFile{
+			ID:   "123",
+			Name: "Test guild",
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := channelJsonFile.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path.Join(testDir, "index.json"), []byte(`{"1": "Test channel"}`), 0660); err != nil {
+		t.Fatal(err)
+	}
+
+	testCsvData := `ID,Timestamp,Contents,Attachments
+000000000000000001,2000-01-01 12:00:00.000000+00:00,Old message,
+000000000000000002,2000-01-02 12:00:00.000000+00:00,New message,
+000000000000000003,2000-01-03 12:00:00.000000+00:00,Another new message,
+`
+
+	if err := os.WriteFile(path.Join(channelDir, "messages.csv"), []byte(testCsvData), 0660); err != nil {
+		t.Fatal(err)
+	}
+
+	// make a model that already has the first message
+	modelPath := path.Join(testDir, "model.gob")
+	testModel := &WordModel{
+		Name:     "Test model",
+		Channels: []int{1},
+		Messages: []ModelMessage{{ID: 1, ChannelID: 1, Words: []string{"old", "message"}}},
+	}
+	testModel.RebuildWords()
+
+	if err := SaveModel(testModel, modelPath); err != nil {
+		t.Fatal(err)
+	}
+
+	modelFile, err := os.Open(modelPath)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testDirFile, err := os.Open(testDir)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := UpdateModel(modelFile, testDirFile); err != nil {
+		t.Errorf("failed to update model: %v", err)
+	}
+
+	modelFile, err = os.Open(modelPath)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updatedModel, err := LoadModel(modelFile)
+
+	if err != nil {
+		t.Errorf("failed to load updated model: %v", err)
+	} else if len(updatedModel.Messages) != 3 || len(updatedModel.Words) != 7 {
+		t.Errorf("updated model has %d messages and %d words instead of 3 and 7",
+			len(updatedModel.Messages), len(updatedModel.Words))
+	}
+
+	if err := modelFile.Close(); err != nil {
+		t.Logf("failed to close test file %s: %v", modelFile.Name(), err)
+	}
+
+	if err := os.RemoveAll(testDir); err != nil {
+		t.Logf("failed to remove the test directory %s: %v", testDir, err)
+	}
+}
+
+func TestLoadModelInfo(t *testing.T) {
+	testDir, err := os.MkdirTemp(os.TempDir(), "hurabotTestLoadModelInfo")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modelPath := path.Join(testDir, "model.gob")
+	testModel := &WordModel{
+		Name:        "Test model",
+		Description: "Test description",
+		Words:       []string{"test", "words"},
+	}
+
+	if err := SaveModel(testModel, modelPath); err != nil {
+		t.Fatal(err)
+	}
+
+	modelFile, err := os.Open(modelPath)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modelInfo, err := LoadModelInfo(modelFile)
+
+	if err != nil {
+		t.Errorf("failed to load model info: %v", err)
+	} else if modelInfo.Name != testModel.Name || modelInfo.Description != testModel.Description {
+		t.Errorf("loaded model info %v doesn't match the test model", modelInfo)
+	}
+
+	if err := modelFile.Close(); err != nil {
+		t.Logf("failed to close test file %s: %v", modelFile.Name(), err)
+	}
+
+	if err := os.RemoveAll(testDir); err != nil {
+		t.Logf("failed to remove the test directory %s: %v", testDir, err)
+	}
+}
+
+func TestModelIDFromFileName(t *testing.T) {
+	testFileNames := map[string]string{
+		"/models/My Model.gob":     "my-model",
+		"friends_chat.gob":         "friends-chat",
+		"models/Ääkköset & stuff!": "ääkköset-stuff",
+		"--.gob":                   "model",
+		"already-a-slug-123.gob":   "already-a-slug-123",
+	}
+
+	for fileName, expectedID := range testFileNames {
+		if id := ModelIDFromFileName(fileName); id != expectedID {
+			t.Errorf("ID for %s was %s instead of %s", fileName, id, expectedID)
+		}
+	}
+}
+
+func TestGenerateWordsFrom(t *testing.T) {
+	wordModel := &WordModel{Words: []string{"the", "cat", "sat", "on", "the", "mat"}}
+	amount := 3
+
+	// the only seed word in the model is always used
+	for i := 0; i < 10; i++ {
+		generatedText := GenerateWordsFrom(wordModel, &amount, []string{"dog", "cat", "barked"})
+		if strings.HasPrefix(generatedText, "cat ") == false {
+			t.Fatalf("expected text starting from the seed word cat, got %q", generatedText)
+		}
+	}
+
+	// unknown seed words are ignored
+	if generatedText := GenerateWordsFrom(wordModel, &amount, []string{"dog"}); generatedText == "" {
+		t.Error("no text was generated without known seed words")
+	}
+}
+
+func TestGenerateWordsContext(t *testing.T) {
+	wordModel := &WordModel{Words: []string{"the", "cat", "sat", "on", "the", "mat"}}
+	amount := 3
+
+	if generatedText, err := GenerateWordsContext(context.Background(), wordModel, &amount, nil); err != nil || generatedText == "" {
+		t.Errorf("expected text to be generated, got %q & %v", generatedText, err)
+	}
+
+	// nothing is generated after cancelling
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if generatedText, err := GenerateWordsContext(ctx, wordModel, &amount, nil); err != context.Canceled || generatedText != "" {
+		t.Errorf("expected the generation to be cancelled, got %q & %v", generatedText, err)
+	}
+}
+
+func TestModelIDUsedBy(t *testing.T) {
+	testDir, err := os.MkdirTemp(os.TempDir(), "hurabotTestModelIDUsedBy")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modelPath := path.Join(testDir, "My Model.gob")
+	if err := SaveModel(&WordModel{ID: ModelIDFromFileName(modelPath), Name: "My Model", Words: []string{"hello"}}, modelPath); err != nil {
+		t.Fatal(err)
+	}
+
+	// filenames that slugify to the same ID collide
+	if otherPath, err := modelIDUsedBy(testDir, "my-model", path.Join(testDir, "my_model.gob")); err != nil || otherPath != modelPath {
+		t.Errorf("expected the ID to be used by %s, got %q & %v", modelPath, otherPath, err)
+	}
+
+	// a model can be made again over its own file
+	if otherPath, err := modelIDUsedBy(testDir, "my-model", modelPath); err != nil || otherPath != "" {
+		t.Errorf("expected the model's own file to be ignored, got %q & %v", otherPath, err)
+	}
+
+	if otherPath, err := modelIDUsedBy(path.Join(testDir, "missing"), "my-model", modelPath); err != nil || otherPath != "" {
+		t.Errorf("expected no models in a missing directory, got %q & %v", otherPath, err)
+	}
+
+	if err := os.RemoveAll(testDir); err != nil {
+		t.Logf("failed to remove the test directory %s: %v", testDir, err)
+	}
+}
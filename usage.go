@@ -0,0 +1,335 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// format of the days of the usage records
+const usageDayFormat = "2006-01-02"
+
+// prefix of anonymized user IDs
+const anonymousUserPrefix = "anon-"
+
+// UsageRecord how many times a user generated text with a model in a guild on a day
+type UsageRecord struct {
+	// Day in UTC as YYYY-MM-DD
+	Day string
+	// User ID, or a hash of it if the store is anonymized
+	User string
+	// Model ID
+	Model string
+	// Guild ID, empty for DMs
+	Guild string
+	// Count of generations
+	Count int64
+}
+
+// usageKey identifies the record of a user, model & guild on a day
+type usageKey struct {
+	Day   string
+	User  string
+	Model string
+	Guild string
+}
+
+// UsageStore the usage counts of the bot saved to a file
+type UsageStore struct {
+	// Records of the usage counts
+	Records []UsageRecord
+
+	// path of the store file
+	filePath string
+	// salt for hashing the user IDs, kept in a file of its own so the usage file alone can't be used to find
+	// the users of the hashes
+	salt string
+	// store hashes of the user IDs instead of the IDs
+	anonymize bool
+	// index of the record of each key in Records
+	index map[usageKey]int
+	// whether there are changes that haven't been saved
+	changed bool
+	// lock for reading & changing the store at the same time
+	mutex sync.Mutex
+}
+
+// UsageCount a user, model or guild & how many times it was used
+type UsageCount struct {
+	// Key the user, model or guild ID
+	Key string
+	// Count of generations
+	Count int64
+}
+
+// UsageTotals the usage counts added up for a period
+type UsageTotals struct {
+	// Total generations
+	Total int64
+	// Users generations by user
+	Users map[string]int64
+	// Models generations by model
+	Models map[string]int64
+	// Guilds generations by guild
+	Guilds map[string]int64
+	// Days generations by day
+	Days map[string]int64
+}
+
+// LoadUsageStore loads a UsageStore from a file, a missing file is an empty store. User IDs are hashed with the
+// salt in saltPath when anonymize is set, including the ones already saved
+func LoadUsageStore(storePath string, saltPath string, anonymize bool) (*UsageStore, error) {
+	store := &UsageStore{filePath: storePath, anonymize: anonymize}
+
+	// usage files made before the salt had a file of its own have the salt in them
+	var oldStore struct {
+		Salt string
+	}
+
+	storeContents, err := os.ReadFile(storePath)
+	if err != nil && os.IsNotExist(err) == false {
+		return nil, fmt.Errorf("failed to read usage file %s: %v", storePath, err)
+	}
+
+	if err == nil {
+		if err := json.Unmarshal(storeContents, store); err != nil {
+			return nil, fmt.Errorf("failed to decode usage file %s: %v", storePath, err)
+		}
+		if err := json.Unmarshal(storeContents, &oldStore); err != nil {
+			return nil, fmt.Errorf("failed to decode usage file %s: %v", storePath, err)
+		}
+	}
+
+	store.salt, err = loadUsageSalt(saltPath, oldStore.Salt, anonymize)
+	if err != nil {
+		return nil, err
+	}
+
+	// saving removes the old salt from the usage file
+	if oldStore.Salt != "" {
+		store.changed = true
+	}
+
+	// records are merged again in case anonymizing gave some of them the same key
+	records := store.Records
+	store.Records = make([]UsageRecord, 0, len(records))
+	store.index = make(map[usageKey]int, len(records))
+
+	for _, record := range records {
+		if anonymize && strings.HasPrefix(record.User, anonymousUserPrefix) == false {
+			record.User = store.hashUser(record.User)
+			store.changed = true
+		}
+		store.add(usageKey{Day: record.Day, User: record.User, Model: record.Model, Guild: record.Guild}, record.Count)
+	}
+
+	return store, nil
+}
+
+// loadUsageSalt loads the salt for hashing user IDs from its file. If the file doesn't exist it's made with oldSalt,
+// or with a new salt if anonymize is set & there's no old salt. Returns an empty salt if nothing is hashed
+func loadUsageSalt(saltPath string, oldSalt string, anonymize bool) (string, error) {
+	saltContents, err := os.ReadFile(saltPath)
+	if err == nil {
+		return strings.TrimSpace(string(saltContents)), nil
+	} else if os.IsNotExist(err) == false {
+		return "", fmt.Errorf("failed to read usage salt file %s: %v", saltPath, err)
+	}
+
+	salt := oldSalt
+	if salt == "" {
+		if anonymize == false {
+			return "", nil
+		}
+
+		saltBytes := make([]byte, 16)
+		if _, err := rand.Read(saltBytes); err != nil {
+			return "", fmt.Errorf("failed to make a salt for the usage file: %v", err)
+		}
+		salt = hex.EncodeToString(saltBytes)
+	}
+
+	// only the bot should be able to read the salt
+	err = WriteFileAtomic(saltPath, 0600, false, func(w io.Writer) error {
+		if _, err := io.WriteString(w, salt+"\n"); err != nil {
+			return fmt.Errorf("failed to write usage salt file %s: %v", saltPath, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return salt, nil
+}
+
+// hashUser returns the anonymized ID of a user
+func (store *UsageStore) hashUser(userID string) string {
+	hash := sha256.Sum256([]byte(store.salt + userID))
+	return anonymousUserPrefix + hex.EncodeToString(hash[:6])
+}
+
+// storedUser returns the ID a user is saved with
+func (store *UsageStore) storedUser(userID string) string {
+	if store.anonymize {
+		return store.hashUser(userID)
+	}
+	return userID
+}
+
+// add adds to the count of a record, the mutex has to be locked
+func (store *UsageStore) add(key usageKey, count int64) {
+	if index, ok := store.index[key]; ok {
+		store.Records[index].Count += count
+		return
+	}
+
+	store.index[key] = len(store.Records)
+	store.Records = append(store.Records, UsageRecord{Day: key.Day, User: key.User, Model: key.Model, Guild: key.Guild, Count: count})
+}
+
+// Record counts a generation by a user with a model in a guild at a time
+func (store *UsageStore) Record(userID string, modelID string, guildID string, at time.Time) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.add(usageKey{
+		Day:   at.UTC().Format(usageDayFormat),
+		User:  store.storedUser(userID),
+		Model: modelID,
+		Guild: guildID,
+	}, 1)
+	store.changed = true
+}
+
+// RemoveUser removes the records of a user, whether they were saved anonymized or not
+func (store *UsageStore) RemoveUser(userID string) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	hashedID := store.hashUser(userID)
+	records := store.Records
+	store.Records = make([]UsageRecord, 0, len(records))
+	store.index = make(map[usageKey]int, len(records))
+
+	for _, record := range records {
+		if record.User == userID || record.User == hashedID {
+			store.changed = true
+			continue
+		}
+		store.add(usageKey{Day: record.Day, User: record.User, Model: record.Model, Guild: record.Guild}, record.Count)
+	}
+}
+
+// Totals adds up the usage from the day of from until the day before until. Only the usage in guildID is counted
+// if it's not empty
+func (store *UsageStore) Totals(from time.Time, until time.Time, guildID string) UsageTotals {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	totals := UsageTotals{
+		Users:  make(map[string]int64),
+		Models: make(map[string]int64),
+		Guilds: make(map[string]int64),
+		Days:   make(map[string]int64),
+	}
+
+	firstDay := from.UTC().Format(usageDayFormat)
+	endDay := until.UTC().Format(usageDayFormat)
+
+	for _, record := range store.Records {
+		// the days sort like strings
+		if record.Day < firstDay || record.Day >= endDay {
+			continue
+		}
+		if guildID != "" && record.Guild != guildID {
+			continue
+		}
+
+		totals.Total += record.Count
+		totals.Users[record.User] += record.Count
+		totals.Models[record.Model] += record.Count
+		totals.Guilds[record.Guild] += record.Count
+		totals.Days[record.Day] += record.Count
+	}
+	return totals
+}
+
+// Save writes the store to its file if it has changed
+func (store *UsageStore) Save() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.changed == false {
+		return nil
+	}
+
+	err := WriteFileAtomic(store.filePath, 0660, keepBackups(), func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		if err := enc.Encode(store); err != nil {
+			return fmt.Errorf("failed to write usage file %s: %v", store.filePath, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	store.changed = false
+	return nil
+}
+
+// TopUsage returns the keys with the highest counts, at most limit of them
+func TopUsage(counts map[string]int64, limit int) []UsageCount {
+	top := make([]UsageCount, 0, len(counts))
+	for key, count := range counts {
+		top = append(top, UsageCount{Key: key, Count: count})
+	}
+
+	sort.Slice(top, func(a, b int) bool {
+		if top[a].Count != top[b].Count {
+			return top[a].Count > top[b].Count
+		}
+		return top[a].Key < top[b].Key
+	})
+
+	if len(top) > limit {
+		top = top[:limit]
+	}
+	return top
+}
+
+// DefaultUsageSaltFile returns the usage salt file from the config, or the usage file with .salt added to its name
+func DefaultUsageSaltFile() (string, error) {
+	if LoadedConfig != nil && LoadedConfig.UsageStats.SaltFile != "" {
+		return LoadedConfig.UsageStats.SaltFile, nil
+	}
+
+	storePath, err := DefaultUsageFile()
+	if err != nil {
+		return "", err
+	}
+	return storePath + ".salt", nil
+}
+
+// DefaultUsageFile returns the usage file from the config, or usage.json next to the executable
+func DefaultUsageFile() (string, error) {
+	if LoadedConfig != nil && LoadedConfig.UsageStats.File != "" {
+		return LoadedConfig.UsageStats.File, nil
+	}
+
+	ed, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the executable directory for the usage file: %v", err)
+	}
+	return path.Join(path.Dir(ed), "usage.json"), nil
+}
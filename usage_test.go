@@ -0,0 +1,150 @@
+package main
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUsageStore(t *testing.T) {
+	testDir, err := os.MkdirTemp(os.TempDir(), "hurabotTestUsageStore")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storePath := path.Join(testDir, "usage.json")
+	saltPath := path.Join(testDir, "usage.json.salt")
+
+	// a missing file is an empty store
+	store, err := LoadUsageStore(storePath, saltPath, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	store.Record("1", "model-a", "20", day)
+	store.Record("1", "model-a", "20", day.Add(time.Hour))
+	store.Record("2", "model-b", "21", day)
+	store.Record("2", "model-a", "20", day.AddDate(0, 0, -1))
+
+	if len(store.Records) != 3 {
+		t.Errorf("expected the same user, model, guild & day to share a record, got %d records", len(store.Records))
+	}
+
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the counts should have been saved
+	store, err = LoadUsageStore(storePath, saltPath, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	totals := store.Totals(day, day.AddDate(0, 0, 1), "")
+	if totals.Total != 3 || totals.Users["1"] != 2 || totals.Models["model-a"] != 2 || totals.Guilds["21"] != 1 {
+		t.Errorf("unexpected totals of the day %+v", totals)
+	}
+
+	totals = store.Totals(day.AddDate(0, 0, -1), day.AddDate(0, 0, 1), "20")
+	if totals.Total != 3 || totals.Days["2024-05-09"] != 1 || totals.Days["2024-05-10"] != 2 {
+		t.Errorf("unexpected totals of guild 20 %+v", totals)
+	}
+
+	if top := TopUsage(totals.Users, 1); len(top) != 1 || top[0].Key != "1" || top[0].Count != 2 {
+		t.Errorf("expected user 1 to be at the top, got %v", top)
+	}
+
+	// anonymizing hashes the saved user IDs too
+	store, err = LoadUsageStore(storePath, saltPath, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Record("1", "model-a", "20", day)
+
+	totals = store.Totals(day, day.AddDate(0, 0, 1), "")
+	if _, ok := totals.Users["1"]; ok {
+		t.Error("user ID was kept in an anonymized store")
+	}
+	if hashed := store.hashUser("1"); totals.Users[hashed] != 3 || strings.HasPrefix(hashed, anonymousUserPrefix) == false {
+		t.Errorf("expected the old & new counts of user 1 under %s, got %v", hashed, totals.Users)
+	}
+
+	// the salt is only in a file of its own
+	if info, err := os.Stat(saltPath); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected a salt file only the owner can read, got %v", err)
+	}
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+	salt, err := os.ReadFile(saltPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storeContents, err := os.ReadFile(storePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(storeContents), strings.TrimSpace(string(salt))) {
+		t.Error("the salt was saved to the usage file")
+	}
+
+	store.RemoveUser("1")
+
+	totals = store.Totals(day.AddDate(0, 0, -1), day.AddDate(0, 0, 1), "")
+	if totals.Total != 2 || len(totals.Users) != 1 {
+		t.Errorf("expected only the counts of user 2 to be left, got %+v", totals)
+	}
+
+	if err := os.RemoveAll(testDir); err != nil {
+		t.Logf("failed to remove the test directory %s: %v", testDir, err)
+	}
+}
+
+func TestUsageStoreOldSalt(t *testing.T) {
+	testDir, err := os.MkdirTemp(os.TempDir(), "hurabotTestUsageStoreOldSalt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storePath := path.Join(testDir, "usage.json")
+	saltPath := path.Join(testDir, "usage.json.salt")
+
+	// usage files used to have the salt in them
+	oldStore := `{"Salt": "oldsalt", "Records": [{"Day": "2024-05-10", "User": "anon-123", "Model": "a", "Guild": "20", "Count": 1}]}`
+	if err := os.WriteFile(storePath, []byte(oldStore), 0660); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := LoadUsageStore(storePath, saltPath, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the hashes made before stay the same
+	if store.salt != "oldsalt" {
+		t.Errorf("expected the old salt to be used, got %q", store.salt)
+	}
+
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	storeContents, err := os.ReadFile(storePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(storeContents), "oldsalt") {
+		t.Errorf("expected the salt to be removed from the usage file, got %s", storeContents)
+	}
+
+	if salt, err := os.ReadFile(saltPath); err != nil || strings.TrimSpace(string(salt)) != "oldsalt" {
+		t.Errorf("expected the old salt in the salt file, got %q & %v", salt, err)
+	}
+
+	if err := os.RemoveAll(testDir); err != nil {
+		t.Logf("failed to remove the test directory %s: %v", testDir, err)
+	}
+}